@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -383,11 +384,26 @@ func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse request body
+	// Parse request body: JSON, or a browser form post with keys like users[0][name]
 	var data map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
-		return
+	switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid form: "+err.Error())
+			return
+		}
+		data = cached.Validator.GetSpec().DecodeMultipartForm(r.MultipartForm)
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid form: "+err.Error())
+			return
+		}
+		data = cached.Validator.GetSpec().DecodeForm(r.PostForm)
+	default:
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
 	}
 
 	// Validate
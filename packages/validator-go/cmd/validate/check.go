@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/example/form-generator/validator/validator"
+)
+
+// runCheck reports malformed condition expressions of a spec read from a JSON file,
+// or from stdin without a file argument. The exit status is 1 if any error is found;
+// warnings from -types do not fail the check.
+func runCheck(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	types := flags.Bool("types", false, "also warn about comparisons that can never match")
+	vars := flags.String("vars", "", "comma-separated `names` of the $ variables the environment provides")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	input := stdin
+	if flags.NArg() > 0 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to open spec: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var spec validator.Spec
+	if err := json.NewDecoder(input).Decode(&spec); err != nil {
+		fmt.Fprintf(stderr, "Failed to parse JSON: %v\n", err)
+		return 1
+	}
+
+	options := validator.CheckOptions{Types: *types}
+	if *vars != "" {
+		options.Variables = strings.Split(*vars, ",")
+	}
+
+	status := 0
+	for _, d := range spec.CheckWithOptions(options) {
+		fmt.Fprintln(stdout, d.String())
+		if caret := d.Caret(); caret != "" {
+			fmt.Fprintln(stdout, indent(caret))
+		}
+		if d.Severity == validator.SeverityError {
+			status = 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/example/form-generator/validator/validator"
+)
+
+// runFmt prints condition expressions in canonical form.
+// Expressions are taken from the arguments, or one per line from stdin.
+// With -check nothing is printed for canonical input and the exit status is 1 if
+// any expression would change. Invalid expressions are reported on stderr.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	check := flags.Bool("check", false, "report expressions that are not canonical instead of printing them")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	expressions := flags.Args()
+	if len(expressions) == 0 {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				expressions = append(expressions, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			fmt.Fprintf(stderr, "Failed to read stdin: %v\n", err)
+			return 1
+		}
+	}
+
+	status := 0
+	for _, expression := range expressions {
+		formatted, err := validator.FormatExpression(expression)
+		if err != nil {
+			printParseError(stderr, expression, err)
+			status = 1
+			continue
+		}
+
+		if *check {
+			if formatted != expression {
+				fmt.Fprintf(stdout, "%s\n\t=> %s\n", expression, formatted)
+				status = 1
+			}
+			continue
+		}
+		fmt.Fprintln(stdout, formatted)
+	}
+
+	return status
+}
+
+// printParseError prints each syntax error of an expression with a caret under it
+func printParseError(w io.Writer, expression string, err error) {
+	parseErrs := validator.ParseErrorsOf(err)
+	if parseErrs == nil {
+		fmt.Fprintf(w, "%s: %v\n", expression, err)
+		return
+	}
+	for _, parseErr := range parseErrs {
+		fmt.Fprintf(w, "%v\n%s\n", parseErr, indent(parseErr.Caret(expression)))
+	}
+}
+
+// indent prefixes every line of s with a tab
+func indent(s string) string {
+	return "\t" + strings.ReplaceAll(s, "\n", "\n\t")
+}
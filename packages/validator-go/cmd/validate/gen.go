@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/example/form-generator/validator/validator"
+)
+
+// runGen prints Go types mirroring a spec read from a JSON file, or from stdin
+// without a file argument
+func runGen(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	pkg := flags.String("package", "forms", "`name` of the package of the generated code")
+	typeName := flags.String("type", "Form", "`name` of the struct of the whole form")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	input := stdin
+	if flags.NArg() > 0 {
+		file, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to open spec: %v\n", err)
+			return 1
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var spec validator.Spec
+	if err := json.NewDecoder(input).Decode(&spec); err != nil {
+		fmt.Fprintf(stderr, "Failed to parse JSON: %v\n", err)
+		return 1
+	}
+
+	source, err := spec.GenerateGo(validator.GenerateOptions{Package: *pkg, TypeName: *typeName})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	stdout.Write(source)
+	return 0
+}
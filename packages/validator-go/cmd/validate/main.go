@@ -1,5 +1,17 @@
 // Package main provides a CLI tool for running validations via stdin/stdout.
 // Used by the cross-language test runner.
+//
+// Without a subcommand it reads a {"spec", "input"} request whole into memory and
+// reports the first error as Validate orders them, so the request size is bounded by
+// memory. Large payloads go through the stream subcommand, which validates the items
+// of repeatable groups as they are read.
+//
+// Subcommands:
+//
+//	validate fmt [-check] [expression...]            print condition expressions in canonical form
+//	validate check [-types] [spec.json]              report malformed condition expressions of a spec
+//	validate stream [-path f] spec.json < data.json  validate a large payload, printing errors as found
+//	validate gen [-package p] [spec.json]            print Go types mirroring a spec
 package main
 
 import (
@@ -25,7 +37,20 @@ type Response struct {
 }
 
 func main() {
-	// Read JSON from stdin
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			os.Exit(runFmt(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "check":
+			os.Exit(runCheck(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "gen":
+			os.Exit(runGen(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		case "stream":
+			os.Exit(runStream(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		}
+	}
+
+	// Read JSON from stdin, whole (see the package comment for large payloads)
 	inputBytes, err := io.ReadAll(os.Stdin)
 	if err != nil {
 		outputError(fmt.Sprintf("Failed to read stdin: %v", err))
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/example/form-generator/validator/validator"
+)
+
+// runStream validates a JSON object read from stdin against a spec read from a JSON
+// file, without reading the object into memory at once. Each error is written to
+// stdout as a line of JSON as soon as it is found, with its field path in the format
+// given by -path (dot, bracket or pointer). The exit status is 1 if the data is
+// invalid or cannot be read.
+func runStream(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("stream", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var pathFormat validator.PathFormat
+	flags.TextVar(&pathFormat, "path", validator.DotPath, "format of error field paths: dot, bracket or pointer")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: validate stream [-path format] spec.json < data.json")
+		return 2
+	}
+
+	specFile, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to open spec: %v\n", err)
+		return 1
+	}
+	defer specFile.Close()
+
+	var spec validator.Spec
+	if err := json.NewDecoder(specFile).Decode(&spec); err != nil {
+		fmt.Fprintf(stderr, "Failed to parse spec: %v\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(stdout)
+
+	v := validator.NewValidator(spec, validator.WithPathFormat(pathFormat))
+	valid, err := v.ValidateStream(json.NewDecoder(bufio.NewReader(stdin)), nil, func(e validator.ValidationError) {
+		encoder.Encode(e)
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to parse JSON: %v\n", err)
+		return 1
+	}
+	if !valid {
+		return 1
+	}
+	return 0
+}
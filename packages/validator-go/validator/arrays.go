@@ -0,0 +1,92 @@
+package validator
+
+// setOperators are the word operators comparing two arrays. A value that is not an
+// array is a set of one element, and null is the empty set.
+var setOperators = map[string]bool{
+	"intersects": true,
+	"subsetOf":   true,
+}
+
+// parseArray parses an array literal: "[" ( value ( "," value )* )? "]"
+func (p *parser) parseArray() (*ArrayNode, error) {
+	start := p.advance().Position.Start // consume '['
+
+	var elements []ASTNode
+	if !p.check(TokenRBracket) {
+		values, err := p.parseValues()
+		if err != nil {
+			return nil, err
+		}
+		elements = values
+	}
+	if !p.match(TokenRBracket) {
+		return nil, p.errorAtCurrent(CodeMissingDelimiter, "expected closing bracket ]")
+	}
+
+	return &ArrayNode{
+		Elements: elements,
+		Position: ASTPosition{Start: start, End: p.previous().Position.End},
+	}, nil
+}
+
+// evaluateArray returns the values of an array literal. An element that evaluates to
+// an array contributes its elements, as in the list of an in operator.
+func (e *evaluator) evaluateArray(node *ArrayNode) []interface{} {
+	values := []interface{}{}
+	for _, element := range node.Elements {
+		values = appendValues(values, e.evaluate(element))
+	}
+	return values
+}
+
+// appendValues appends a value to a list, or its elements if it is an array
+func appendValues(list []interface{}, value interface{}) []interface{} {
+	if arr, ok := value.([]interface{}); ok {
+		return append(list, arr...)
+	}
+	return append(list, value)
+}
+
+// containsValue reports whether a list has an element equal to value
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, element := range list {
+		if isEqual(element, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// toSet returns the elements of an operand of a set operator
+func toSet(value interface{}) []interface{} {
+	if value == nil {
+		return nil
+	}
+	return appendValues(nil, value)
+}
+
+// evaluateSetOperator compares two arrays:
+//
+//	a intersects b  => some element of a is in b
+//	a subsetOf b    => every element of a is in b (true if a is empty)
+func evaluateSetOperator(operator string, left, right interface{}) bool {
+	a, b := toSet(left), toSet(right)
+	switch operator {
+	case "intersects":
+		for _, element := range a {
+			if containsValue(b, element) {
+				return true
+			}
+		}
+		return false
+	case "subsetOf":
+		for _, element := range a {
+			if !containsValue(b, element) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
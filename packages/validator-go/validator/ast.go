@@ -0,0 +1,392 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Visitor is called by Walk for each node of an AST.
+// If Visit returns a non-nil visitor w, Walk visits the children of the node
+// with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node ASTNode) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order
+func Walk(v Visitor, node ASTNode) {
+	if node == nil {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	for _, child := range Children(node) {
+		Walk(v, child)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(ASTNode) bool
+
+func (f inspector) Visit(node ASTNode) Visitor {
+	if node != nil && f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order, calling fn for each node.
+// If fn returns false, the children of that node are skipped.
+func Inspect(node ASTNode, fn func(ASTNode) bool) {
+	Walk(inspector(fn), node)
+}
+
+// Children returns the direct child nodes of a node in source order
+func Children(node ASTNode) []ASTNode {
+	switch n := node.(type) {
+	case *BinaryNode:
+		return []ASTNode{n.Left, n.Right}
+	case *UnaryNode:
+		return []ASTNode{n.Operand}
+	case *InNode:
+		return append([]ASTNode{n.Value}, n.List...)
+	case *ArrayNode:
+		return n.Elements
+	case *GroupNode:
+		return []ASTNode{n.Expression}
+	case *TernaryNode:
+		return []ASTNode{n.Condition, n.TrueValue, n.FalseValue}
+	case *QuantifierNode:
+		return []ASTNode{n.Expression}
+	default:
+		return nil
+	}
+}
+
+// Rewrite returns a copy of an AST with every node replaced by the result of fn.
+// Nodes are visited bottom-up: fn receives a copy of the node whose children have
+// already been rewritten, and returns it (possibly modified) or a replacement.
+// The original tree is never modified, so ASTs of compiled expressions can be
+// rewritten safely.
+func Rewrite(node ASTNode, fn func(ASTNode) ASTNode) ASTNode {
+	if node == nil {
+		return nil
+	}
+
+	var copied ASTNode
+	switch n := node.(type) {
+	case *BinaryNode:
+		c := *n
+		c.Left = Rewrite(n.Left, fn)
+		c.Right = Rewrite(n.Right, fn)
+		copied = &c
+	case *UnaryNode:
+		c := *n
+		c.Operand = Rewrite(n.Operand, fn)
+		copied = &c
+	case *InNode:
+		c := *n
+		c.Value = Rewrite(n.Value, fn)
+		c.List = make([]ASTNode, len(n.List))
+		for i, item := range n.List {
+			c.List[i] = Rewrite(item, fn)
+		}
+		copied = &c
+	case *ArrayNode:
+		c := *n
+		c.Elements = make([]ASTNode, len(n.Elements))
+		for i, element := range n.Elements {
+			c.Elements[i] = Rewrite(element, fn)
+		}
+		copied = &c
+	case *GroupNode:
+		c := *n
+		c.Expression = Rewrite(n.Expression, fn)
+		copied = &c
+	case *TernaryNode:
+		c := *n
+		c.Condition = Rewrite(n.Condition, fn)
+		c.TrueValue = Rewrite(n.TrueValue, fn)
+		c.FalseValue = Rewrite(n.FalseValue, fn)
+		copied = &c
+	case *QuantifierNode:
+		c := *n
+		c.Expression = Rewrite(n.Expression, fn)
+		copied = &c
+	case *PathNode:
+		c := *n
+		c.Segments = append([]PathSegment(nil), n.Segments...)
+		copied = &c
+	case *VariableNode:
+		c := *n
+		c.Segments = append([]PathSegment(nil), n.Segments...)
+		copied = &c
+	case *LiteralNode:
+		c := *n
+		copied = &c
+	default:
+		copied = node
+	}
+
+	return fn(copied)
+}
+
+// JSON encoding of the AST, matching the node structure in docs/CONDITION-PARSER.md
+
+// MarshalJSON encodes a binary node
+func (n *BinaryNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string      `json:"type"`
+		Operator string      `json:"operator"`
+		Left     ASTNode     `json:"left"`
+		Right    ASTNode     `json:"right"`
+		Position ASTPosition `json:"position"`
+	}{n.NodeType(), n.Operator, n.Left, n.Right, n.Position})
+}
+
+// MarshalJSON encodes a unary node
+func (n *UnaryNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type     string      `json:"type"`
+		Operator string      `json:"operator"`
+		Operand  ASTNode     `json:"operand"`
+		Position ASTPosition `json:"position"`
+	}{n.NodeType(), n.Operator, n.Operand, n.Position})
+}
+
+// MarshalJSON encodes an in node
+func (n *InNode) MarshalJSON() ([]byte, error) {
+	list := n.List
+	if list == nil {
+		list = []ASTNode{}
+	}
+	return json.Marshal(struct {
+		Type     string      `json:"type"`
+		Negated  bool        `json:"negated"`
+		Value    ASTNode     `json:"value"`
+		List     []ASTNode   `json:"list"`
+		Position ASTPosition `json:"position"`
+	}{n.NodeType(), n.Negated, n.Value, list, n.Position})
+}
+
+// MarshalJSON encodes an array literal
+func (n *ArrayNode) MarshalJSON() ([]byte, error) {
+	elements := n.Elements
+	if elements == nil {
+		elements = []ASTNode{}
+	}
+	return json.Marshal(struct {
+		Type     string      `json:"type"`
+		Elements []ASTNode   `json:"elements"`
+		Position ASTPosition `json:"position"`
+	}{n.NodeType(), elements, n.Position})
+}
+
+// MarshalJSON encodes a path node
+func (n *PathNode) MarshalJSON() ([]byte, error) {
+	segments := n.Segments
+	if segments == nil {
+		segments = []PathSegment{}
+	}
+	return json.Marshal(struct {
+		Type     string        `json:"type"`
+		Relative bool          `json:"relative"`
+		LevelsUp int           `json:"levelsUp"`
+		Segments []PathSegment `json:"segments"`
+		Position ASTPosition   `json:"position"`
+	}{n.NodeType(), n.Relative, n.LevelsUp, segments, n.Position})
+}
+
+// MarshalJSON encodes a variable node
+func (n *VariableNode) MarshalJSON() ([]byte, error) {
+	segments := n.Segments
+	if segments == nil {
+		segments = []PathSegment{}
+	}
+	return json.Marshal(struct {
+		Type     string        `json:"type"`
+		Name     string        `json:"name"`
+		Segments []PathSegment `json:"segments"`
+		Position ASTPosition   `json:"position"`
+	}{n.NodeType(), n.Name, segments, n.Position})
+}
+
+// MarshalJSON encodes a literal node
+func (n *LiteralNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type      string      `json:"type"`
+		ValueType string      `json:"valueType"`
+		Value     interface{} `json:"value"`
+		Position  ASTPosition `json:"position"`
+	}{n.NodeType(), n.ValueType, n.Value, n.Position})
+}
+
+// MarshalJSON encodes a group node
+func (n *GroupNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type       string      `json:"type"`
+		Expression ASTNode     `json:"expression"`
+		Position   ASTPosition `json:"position"`
+	}{n.NodeType(), n.Expression, n.Position})
+}
+
+// MarshalJSON encodes a ternary node
+func (n *TernaryNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type       string      `json:"type"`
+		Condition  ASTNode     `json:"condition"`
+		TrueValue  ASTNode     `json:"trueValue"`
+		FalseValue ASTNode     `json:"falseValue"`
+		Position   ASTPosition `json:"position"`
+	}{n.NodeType(), n.Condition, n.TrueValue, n.FalseValue, n.Position})
+}
+
+// MarshalJSON encodes a quantifier node
+func (n *QuantifierNode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type       string      `json:"type"`
+		Quantifier string      `json:"quantifier"`
+		Expression ASTNode     `json:"expression"`
+		Position   ASTPosition `json:"position"`
+	}{n.NodeType(), n.Quantifier, n.Expression, n.Position})
+}
+
+// jsonSegment is the JSON form of a path segment; index values are numbers
+type jsonSegment struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON encodes a path segment
+func (s PathSegment) MarshalJSON() ([]byte, error) {
+	segment := jsonSegment{Type: s.Type}
+	switch s.Type {
+	case "wildcard":
+	case "index":
+		if idx, err := strconv.Atoi(s.Value); err == nil {
+			segment.Value = idx
+		} else {
+			segment.Value = s.Value
+		}
+	default:
+		segment.Value = s.Value
+	}
+	return json.Marshal(segment)
+}
+
+// UnmarshalJSON decodes a path segment
+func (s *PathSegment) UnmarshalJSON(data []byte) error {
+	var segment jsonSegment
+	if err := json.Unmarshal(data, &segment); err != nil {
+		return err
+	}
+
+	s.Type = segment.Type
+	switch v := segment.Value.(type) {
+	case nil:
+		s.Value = ""
+	case string:
+		s.Value = v
+	case float64:
+		s.Value = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Errorf("invalid path segment value: %v", v)
+	}
+	return nil
+}
+
+// jsonNode holds the fields of any encoded node; children stay raw until the type is known
+type jsonNode struct {
+	Type       string            `json:"type"`
+	Operator   string            `json:"operator"`
+	Left       json.RawMessage   `json:"left"`
+	Right      json.RawMessage   `json:"right"`
+	Operand    json.RawMessage   `json:"operand"`
+	Negated    bool              `json:"negated"`
+	Value      json.RawMessage   `json:"value"`
+	List       []json.RawMessage `json:"list"`
+	Elements   []json.RawMessage `json:"elements"`
+	Relative   bool              `json:"relative"`
+	LevelsUp   int               `json:"levelsUp"`
+	Segments   []PathSegment     `json:"segments"`
+	ValueType  string            `json:"valueType"`
+	Expression json.RawMessage   `json:"expression"`
+	Condition  json.RawMessage   `json:"condition"`
+	TrueValue  json.RawMessage   `json:"trueValue"`
+	FalseValue json.RawMessage   `json:"falseValue"`
+	Quantifier string            `json:"quantifier"`
+	Name       string            `json:"name"`
+	Position   ASTPosition       `json:"position"`
+}
+
+// UnmarshalAST decodes an AST from its JSON encoding
+func UnmarshalAST(data []byte) (ASTNode, error) {
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+
+	// child decodes a required child node
+	var err error
+	child := func(raw json.RawMessage, name string) ASTNode {
+		if err != nil {
+			return nil
+		}
+		if len(raw) == 0 || string(raw) == "null" {
+			err = fmt.Errorf("%s node without %s", n.Type, name)
+			return nil
+		}
+		var node ASTNode
+		node, err = UnmarshalAST(raw)
+		return node
+	}
+
+	var node ASTNode
+	switch n.Type {
+	case "Binary":
+		node = &BinaryNode{Operator: n.Operator, Left: child(n.Left, "left"), Right: child(n.Right, "right"), Position: n.Position}
+	case "Unary":
+		node = &UnaryNode{Operator: n.Operator, Operand: child(n.Operand, "operand"), Position: n.Position}
+	case "In":
+		in := &InNode{Negated: n.Negated, Value: child(n.Value, "value"), Position: n.Position}
+		for _, raw := range n.List {
+			in.List = append(in.List, child(raw, "list item"))
+		}
+		node = in
+	case "Array":
+		array := &ArrayNode{Position: n.Position}
+		for _, raw := range n.Elements {
+			array.Elements = append(array.Elements, child(raw, "element"))
+		}
+		node = array
+	case "Path":
+		node = &PathNode{Relative: n.Relative, LevelsUp: n.LevelsUp, Segments: n.Segments, Position: n.Position}
+	case "Variable":
+		node = &VariableNode{Name: n.Name, Segments: n.Segments, Position: n.Position}
+	case "Literal":
+		literal := &LiteralNode{ValueType: n.ValueType, Position: n.Position}
+		if len(n.Value) > 0 {
+			err = json.Unmarshal(n.Value, &literal.Value)
+		}
+		node = literal
+	case "Group":
+		node = &GroupNode{Expression: child(n.Expression, "expression"), Position: n.Position}
+	case "Ternary":
+		node = &TernaryNode{
+			Condition:  child(n.Condition, "condition"),
+			TrueValue:  child(n.TrueValue, "trueValue"),
+			FalseValue: child(n.FalseValue, "falseValue"),
+			Position:   n.Position,
+		}
+	case "Quantifier":
+		node = &QuantifierNode{Quantifier: n.Quantifier, Expression: child(n.Expression, "expression"), Position: n.Position}
+	default:
+		return nil, fmt.Errorf("unknown AST node type %q", n.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
@@ -0,0 +1,162 @@
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// benchmarkCase is a spec of the productnft fixtures with the inputs of its cases
+type benchmarkCase struct {
+	spec   Spec
+	inputs []map[string]interface{}
+}
+
+// loadProductNftCases loads the productnft test suites as benchmark cases
+func loadProductNftCases(b *testing.B) []benchmarkCase {
+	casesDir, err := findTestCasesDir()
+	if err != nil {
+		b.Skip(err)
+	}
+
+	var cases []benchmarkCase
+	for _, name := range []string{"productnft.json", "productnft-full.json"} {
+		content, err := os.ReadFile(filepath.Join(casesDir, name))
+		if err != nil {
+			b.Fatal(err)
+		}
+		var suite testSuiteData
+		if err := json.Unmarshal(content, &suite); err != nil {
+			b.Fatal(err)
+		}
+		for _, def := range suite.Tests {
+			bc := benchmarkCase{spec: convertSpecToValidator(def.Spec)}
+			for _, tc := range def.Cases {
+				bc.inputs = append(bc.inputs, convertInputData(def.Spec, tc.Input))
+			}
+			cases = append(cases, bc)
+		}
+	}
+	return cases
+}
+
+// BenchmarkValidateProductNft validates every productnft case with validators built once
+func BenchmarkValidateProductNft(b *testing.B) {
+	cases := loadProductNftCases(b)
+	validators := make([]*Validator, len(cases))
+	for i, bc := range cases {
+		validators[i] = NewValidator(bc.spec)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i, bc := range cases {
+			for _, input := range bc.inputs {
+				validators[i].Validate(input)
+			}
+		}
+	}
+}
+
+// BenchmarkNewValidatorProductNft measures compiling the productnft specs
+func BenchmarkNewValidatorProductNft(b *testing.B) {
+	cases := loadProductNftCases(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, bc := range cases {
+			NewValidator(bc.spec)
+		}
+	}
+}
+
+// bulkImport returns a spec of a bulk import form and a payload of rows, every tenth
+// of which is invalid
+func bulkImport(rows int) (Spec, map[string]interface{}) {
+	spec := Spec{Fields: []Field{
+		{Name: "mode", Type: "text"},
+		{Name: "rows", Type: "group", Multiple: true, Fields: []Field{
+			{Name: "sku", Type: "text", Rules: map[string]interface{}{"required": true, "match": "^[A-Z]{3}-[0-9]{4}$"}},
+			{Name: "email", Type: "email", Rules: map[string]interface{}{"email": true}},
+			{Name: "qty", Type: "number", Rules: map[string]interface{}{"min": 1, "max": "...mode == 'wholesale' ? 10000 : 100"}},
+			{Name: "note", Type: "text", Rules: map[string]interface{}{"required": ".qty > 50", "maxlength": 200}},
+			{Name: "options", Type: "group", Multiple: true, Fields: []Field{
+				{Name: "code", Type: "text", Rules: map[string]interface{}{"required": true, "in": "red,green,blue"}},
+			}},
+		}},
+	}}
+
+	items := make([]interface{}, rows)
+	for i := range items {
+		row := map[string]interface{}{
+			"sku":     fmt.Sprintf("ABC-%04d", i%10000),
+			"email":   fmt.Sprintf("buyer%d@example.com", i),
+			"qty":     float64(i%40 + 1),
+			"options": []interface{}{map[string]interface{}{"code": "red"}, map[string]interface{}{"code": "blue"}},
+		}
+		if i%10 == 0 {
+			row["sku"] = "bad"
+			row["qty"] = float64(75)
+		}
+		items[i] = row
+	}
+	return spec, map[string]interface{}{"mode": "retail", "rows": items}
+}
+
+// BenchmarkValidateBulkImport compares sequential and parallel validation of large
+// repeatable groups; the parallel runs use GOMAXPROCS workers (go test -cpu 1,4,8)
+func BenchmarkValidateBulkImport(b *testing.B) {
+	workerCounts := []int{1}
+	if procs := runtime.GOMAXPROCS(0); procs > 1 {
+		workerCounts = append(workerCounts, procs)
+	}
+	for _, rows := range []int{1000, 5000} {
+		spec, data := bulkImport(rows)
+		for _, workers := range workerCounts {
+			v := NewValidator(spec, WithParallelism(workers))
+			b.Run(fmt.Sprintf("rows=%d/workers=%d", rows, workers), func(b *testing.B) {
+				b.ReportAllocs()
+				for n := 0; n < b.N; n++ {
+					v.Validate(data)
+				}
+				b.ReportMetric(float64(rows*b.N)/b.Elapsed().Seconds(), "rows/s")
+			})
+		}
+	}
+}
+
+// BenchmarkValidateStreamBulkImport compares validating a bulk import payload as it is
+// decoded with decoding it whole before validation
+func BenchmarkValidateStreamBulkImport(b *testing.B) {
+	spec, data := bulkImport(5000)
+	payload, err := json.Marshal(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+	v := NewValidator(spec)
+
+	b.Run("unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			var decoded map[string]interface{}
+			if err := json.Unmarshal(payload, &decoded); err != nil {
+				b.Fatal(err)
+			}
+			v.Validate(decoded)
+		}
+	})
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			if _, err := v.ValidateStream(json.NewDecoder(bytes.NewReader(payload)), nil, func(ValidationError) {}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,394 @@
+package validator
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checkboxFieldTypes are the spec field types whose values bind to a bool as checked
+// or not, where a form sends "on" or a list of checked values
+var checkboxFieldTypes = map[string]bool{
+	"checkbox": true,
+	"switch":   true,
+}
+
+// Binding errors use this rule name
+const bindRule = "bind"
+
+// Bind decodes form data, usually after it has been validated, into target, a
+// pointer to a struct or map. Struct fields are matched like in ValidateStruct, by
+// their form tag, else their json tag, else the Go field name. Values are coerced
+// to the Go type of the target, using the spec field type where it matters:
+//
+//   - numbers and strings of numbers bind to every numeric kind, if they fit;
+//   - checkbox and switch values bind to a bool as checked ("on", "1", "yes", a
+//     non-empty list), other fields to a bool only from true/false values;
+//   - date strings bind to a time.Time, in any layout conditions accept for date,
+//     datetime, time and month fields, else in RFC 3339; times without an offset
+//     are read in UTC;
+//   - a single value binds to a slice as a list of one, so a multichoice field
+//     with one choice still fills a []string;
+//   - strings bind to types implementing encoding.TextUnmarshaler.
+//
+// Empty values leave numbers, bools and times at their zero value and pointers nil;
+// fields missing from data are left unchanged. Values that cannot be decoded are
+// reported as errors with the rule "bind" at their data path, and the rest of the
+// data is still decoded. Bind returns an error only if target is not a non-nil
+// pointer to a struct or map.
+func (v *Validator) Bind(data map[string]interface{}, target interface{}) (*ValidationResult, error) {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil, fmt.Errorf("cannot bind to %T, want a non-nil pointer", target)
+	}
+	if kind := rv.Elem().Kind(); kind != reflect.Struct && kind != reflect.Map {
+		return nil, fmt.Errorf("cannot bind to %T, want a pointer to a struct or map", target)
+	}
+
+	c := v.config.Load()
+	b := &binder{spec: c.spec, pathFormat: c.pathFormat}
+	b.bind(data, rv.Elem(), nil)
+	return &ValidationResult{IsValid: len(b.errors) == 0, Errors: b.errors}, nil
+}
+
+// binder decodes form data into Go values, collecting the values it cannot decode
+type binder struct {
+	spec       Spec
+	pathFormat PathFormat
+	errors     []ValidationError
+}
+
+// fail records a value at path that cannot be decoded
+func (b *binder) fail(path []string, value interface{}, message string) {
+	b.errors = append(b.errors, ValidationError{
+		Field:   b.pathFormat.Format(path),
+		Rule:    bindRule,
+		Message: message,
+		Value:   value,
+	})
+}
+
+// bind decodes the value at path into target
+func (b *binder) bind(value interface{}, target reflect.Value, path []string) {
+	if target.Kind() == reflect.Pointer {
+		if value == nil || isEmpty(value) && target.Type().Elem().Kind() != reflect.String {
+			target.Set(reflect.Zero(target.Type()))
+			return
+		}
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		b.bind(value, target.Elem(), path)
+		return
+	}
+
+	if target.Type() == timeType {
+		b.bindTime(value, target, path)
+		return
+	}
+	if s, ok := value.(string); ok && target.Kind() != reflect.String {
+		if u, ok := target.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if err := u.UnmarshalText([]byte(s)); err != nil {
+				b.fail(path, value, "Please enter a valid value")
+			}
+			return
+		}
+	}
+
+	switch target.Kind() {
+	case reflect.Interface:
+		if value == nil {
+			target.Set(reflect.Zero(target.Type()))
+		} else if reflect.TypeOf(value).AssignableTo(target.Type()) {
+			target.Set(reflect.ValueOf(value))
+		} else {
+			b.fail(path, value, "Please enter a valid value")
+		}
+	case reflect.String:
+		if target.Type() == reflect.TypeOf(json.Number("")) {
+			b.bindNumber(value, target, path)
+			return
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			b.fail(path, value, "Please enter a valid value")
+		default:
+			target.SetString(toString(value))
+		}
+	case reflect.Bool:
+		b.bindBool(value, target, path)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		b.bindNumber(value, target, path)
+	case reflect.Slice, reflect.Array:
+		b.bindList(value, target, path)
+	case reflect.Map:
+		b.bindMap(value, target, path)
+	case reflect.Struct:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			if value != nil {
+				b.fail(path, value, "Please enter a valid value")
+			}
+			return
+		}
+		b.bindStruct(m, target, path)
+	default:
+		b.fail(path, value, "Please enter a valid value")
+	}
+}
+
+// bindNumber decodes a number or a string of a number into a numeric target or a
+// json.Number, checking that it is whole and in range for integer kinds
+func (b *binder) bindNumber(value interface{}, target reflect.Value, path []string) {
+	if isEmpty(value) {
+		target.Set(reflect.Zero(target.Type()))
+		return
+	}
+	if _, isBool := value.(bool); isBool {
+		b.fail(path, value, "Please enter a valid number")
+		return
+	}
+	n, ok := toNumber(value)
+	if !ok || math.IsInf(n, 0) {
+		b.fail(path, value, "Please enter a valid number")
+		return
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(strings.TrimSpace(toString(value)))
+	case reflect.Float32, reflect.Float64:
+		if target.OverflowFloat(n) {
+			b.fail(path, value, "Please enter a valid number")
+			return
+		}
+		target.SetFloat(n)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		digits, whole := wholeNumber(value, n)
+		if !whole {
+			b.fail(path, value, "Please enter a whole number")
+			return
+		}
+		i, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil || target.OverflowInt(i) {
+			b.fail(path, value, "Please enter a value between "+
+				strconv.FormatInt(minInt(target.Type()), 10)+" and "+strconv.FormatInt(maxInt(target.Type()), 10))
+			return
+		}
+		target.SetInt(i)
+	default:
+		digits, whole := wholeNumber(value, n)
+		if !whole {
+			b.fail(path, value, "Please enter a whole number")
+			return
+		}
+		u, err := strconv.ParseUint(digits, 10, 64)
+		if err != nil || target.OverflowUint(u) {
+			b.fail(path, value, "Please enter a value between 0 and "+strconv.FormatUint(maxUint(target.Type()), 10))
+			return
+		}
+		target.SetUint(u)
+	}
+}
+
+// wholeNumber returns the digits of a whole number, taken as written from strings of
+// integers so that large IDs stay exact; whole is false for numbers with a fraction
+func wholeNumber(value interface{}, n float64) (digits string, whole bool) {
+	if n != math.Trunc(n) {
+		return "", false
+	}
+	digits = strings.TrimSpace(toString(value))
+	if strings.ContainsAny(digits, ".eE") {
+		digits = strconv.FormatFloat(n, 'f', 0, 64)
+	}
+	return digits, true
+}
+
+// minInt and maxInt return the range of a signed integer type
+func minInt(t reflect.Type) int64 {
+	return -1 << (t.Bits() - 1)
+}
+
+func maxInt(t reflect.Type) int64 {
+	return 1<<(t.Bits()-1) - 1
+}
+
+// maxUint returns the largest value of an unsigned integer type
+func maxUint(t reflect.Type) uint64 {
+	return math.MaxUint64 >> (64 - t.Bits())
+}
+
+// bindBool decodes a bool. Checkbox fields are checked by any value a form sends
+// for a checked box; other fields take true/false values.
+func (b *binder) bindBool(value interface{}, target reflect.Value, path []string) {
+	if isEmpty(value) {
+		target.SetBool(false)
+		return
+	}
+	if v, ok := value.(bool); ok {
+		target.SetBool(v)
+		return
+	}
+
+	if checkboxFieldTypes[b.spec.fieldTypeAt(path)] {
+		switch v := value.(type) {
+		case []interface{}:
+			target.SetBool(len(v) > 0)
+			return
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "0", "false", "off", "no", "n":
+				target.SetBool(false)
+			default:
+				target.SetBool(true)
+			}
+			return
+		}
+		if n, ok := toFloat64(value); ok {
+			target.SetBool(n != 0)
+			return
+		}
+	} else if s, ok := value.(string); ok {
+		if v, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+			target.SetBool(v)
+			return
+		}
+	}
+	b.fail(path, value, "Please enter true or false")
+}
+
+// bindTime decodes a date string, in the layouts of the field type
+func (b *binder) bindTime(value interface{}, target reflect.Value, path []string) {
+	if isEmpty(value) {
+		target.Set(reflect.Zero(target.Type()))
+		return
+	}
+	var t time.Time
+	var ok bool
+	switch v := value.(type) {
+	case time.Time:
+		t, ok = v, true
+	case string:
+		if temporalFieldTypes[b.spec.fieldTypeAt(path)] {
+			t, ok = parseDateTime(v, time.UTC)
+		} else {
+			var err error
+			t, err = time.Parse(time.RFC3339, strings.TrimSpace(v))
+			ok = err == nil
+		}
+	}
+	if !ok {
+		b.fail(path, value, "Please enter a valid date")
+		return
+	}
+	target.Set(reflect.ValueOf(t))
+}
+
+// bindList decodes a list, or the items of a keyed collection in key order, into a
+// slice or array; a single value is a list of one
+func (b *binder) bindList(value interface{}, target reflect.Value, path []string) {
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return
+	}
+	items, segments, ok := repeatableItems(value)
+	if !ok {
+		if s, isString := value.(string); isString && target.Type().Elem().Kind() == reflect.Uint8 {
+			target.Set(reflect.ValueOf([]byte(s)).Convert(target.Type()))
+			return
+		}
+		if _, isMap := value.(map[string]interface{}); isMap {
+			b.fail(path, value, "Please enter a list of values")
+			return
+		}
+		items, segments = []interface{}{value}, []string{"0"}
+	}
+
+	if target.Kind() == reflect.Array {
+		if len(items) > target.Len() {
+			b.fail(path, value, "Please enter no more than "+strconv.Itoa(target.Len())+" values")
+			return
+		}
+		for i := 0; i < target.Len(); i++ {
+			if i < len(items) {
+				b.bind(items[i], target.Index(i), AppendToPath(path, segments[i]))
+			} else {
+				target.Index(i).Set(reflect.Zero(target.Type().Elem()))
+			}
+		}
+		return
+	}
+
+	slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+	for i, item := range items {
+		b.bind(item, slice.Index(i), AppendToPath(path, segments[i]))
+	}
+	target.Set(slice)
+}
+
+// bindMap decodes a group into a map with string keys
+func (b *binder) bindMap(value interface{}, target reflect.Value, path []string) {
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok || target.Type().Key().Kind() != reflect.String {
+		b.fail(path, value, "Please enter a valid value")
+		return
+	}
+	if target.IsNil() {
+		target.Set(reflect.MakeMapWithSize(target.Type(), len(m)))
+	}
+	for key, item := range m {
+		elem := reflect.New(target.Type().Elem()).Elem()
+		b.bind(item, elem, AppendToPath(path, key))
+		target.SetMapIndex(reflect.ValueOf(key).Convert(target.Type().Key()), elem)
+	}
+}
+
+// bindStruct decodes a group into the exported fields of a struct
+func (b *binder) bindStruct(m map[string]interface{}, target reflect.Value, path []string) {
+	rt := target.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		name, _, skip := fieldTag(sf)
+		if skip {
+			continue
+		}
+		fv := target.Field(i)
+
+		if sf.Anonymous && name == "" {
+			embedded := fv
+			if embedded.Kind() == reflect.Pointer && embedded.Type().Elem().Kind() == reflect.Struct {
+				if embedded.IsNil() {
+					if !embedded.CanSet() {
+						continue
+					}
+					embedded.Set(reflect.New(embedded.Type().Elem()))
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && !isTextStruct(embedded.Type()) {
+				b.bindStruct(m, embedded, path)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if value, ok := m[name]; ok {
+			b.bind(value, fv, AppendToPath(path, name))
+		}
+	}
+}
@@ -1,75 +1,162 @@
 package validator
 
 import (
+	"container/list"
 	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 )
 
-// ConditionParser parses and evaluates condition expressions
+// notInPattern matches the "not in" operator with any whitespace between the words
+var notInPattern = regexp.MustCompile(`^not\s+in\b`)
+
+// maxCachedExpressions is the number of expressions a ConditionParser keeps compiled;
+// beyond it, the least recently used are dropped
+const maxCachedExpressions = 4096
+
+// ConditionParser parses and evaluates condition expressions.
+// Expressions passed to Compile (and their parse failures) are cached, up to
+// maxCachedExpressions of them; the cache is safe for concurrent use. Parse, Evaluate,
+// EvaluateValue and Explain use cached expressions but do not add to the cache, so
+// that one-off expressions, such as ones written by users, do not accumulate.
 type ConditionParser struct {
-	cache map[string]ASTNode
+	mu        sync.Mutex
+	cache     map[string]*list.Element // Elements of recent, holding a *compileResult
+	recent    *list.List               // Cached results, most recently used first
+	limits    Limits
+	fieldType func(path []string) string
 }
 
-// NewConditionParser creates a new condition parser
+// compileResult is a cached outcome of compiling an expression
+type compileResult struct {
+	expression string
+	expr       *Expression
+	err        error
+}
+
+// NewConditionParser creates a new condition parser enforcing DefaultLimits
 func NewConditionParser() *ConditionParser {
+	return NewConditionParserWithLimits(DefaultLimits())
+}
+
+// NewConditionParserWithLimits creates a condition parser enforcing the given limits.
+// Expressions it compiles keep the limits for every later evaluation.
+func NewConditionParserWithLimits(limits Limits) *ConditionParser {
 	return &ConditionParser{
-		cache: make(map[string]ASTNode),
+		cache:  make(map[string]*list.Element),
+		recent: list.New(),
+		limits: limits,
 	}
 }
 
-// Parse parses a condition expression into an AST
-func (cp *ConditionParser) Parse(expression string) (ASTNode, error) {
-	// Check cache
-	if ast, ok := cp.cache[expression]; ok {
-		return ast, nil
+// NewSpecConditionParser creates a condition parser for the expressions of a spec.
+// Values of date, datetime, time and month fields are compared as times, so that
+// ".end_date > .start_date" holds for "2024-01-10" and "2024-1-9".
+func NewSpecConditionParser(spec Spec, limits Limits) *ConditionParser {
+	cp := NewConditionParserWithLimits(limits)
+	cp.fieldType = spec.fieldTypeAt
+	return cp
+}
+
+// Limits returns the limits the parser enforces
+func (cp *ConditionParser) Limits() Limits {
+	return cp.limits
+}
+
+// Compile compiles a condition expression, reusing a cached result when available
+func (cp *ConditionParser) Compile(expression string) (*Expression, error) {
+	if cached, ok := cp.cached(expression); ok {
+		return cached.expr, cached.err
 	}
 
-	// Tokenize
-	lexer := newLexer(expression)
-	tokens, err := lexer.tokenize()
-	if err != nil {
-		return nil, err
+	expr, err := cp.compile(expression)
+
+	// Cache
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if element, ok := cp.cache[expression]; ok {
+		existing := element.Value.(*compileResult)
+		return existing.expr, existing.err // another goroutine compiled it first
+	}
+	cp.cache[expression] = cp.recent.PushFront(&compileResult{expression: expression, expr: expr, err: err})
+	if cp.recent.Len() > maxCachedExpressions {
+		oldest := cp.recent.Remove(cp.recent.Back()).(*compileResult)
+		delete(cp.cache, oldest.expression)
+	}
+	return expr, err
+}
+
+// cached returns the cached result of an expression, marking it as recently used
+func (cp *ConditionParser) cached(expression string) (*compileResult, bool) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	element, ok := cp.cache[expression]
+	if !ok {
+		return nil, false
 	}
+	cp.recent.MoveToFront(element)
+	return element.Value.(*compileResult), true
+}
 
-	// Parse
-	parser := newParser(tokens)
-	ast, err := parser.parse()
-	if err != nil {
-		return nil, err
+// compile compiles an expression for the parser without caching it
+func (cp *ConditionParser) compile(expression string) (*Expression, error) {
+	expr, err := compile(expression, cp.limits)
+	if expr != nil {
+		expr.fieldType = cp.fieldType
 	}
+	return expr, err
+}
 
-	// Cache
-	cp.cache[expression] = ast
+// lookup returns the cached result of an expression, or compiles it without adding
+// it to the cache
+func (cp *ConditionParser) lookup(expression string) (*Expression, error) {
+	if cached, ok := cp.cached(expression); ok {
+		return cached.expr, cached.err
+	}
+	return cp.compile(expression)
+}
 
-	return ast, nil
+// Parse parses a condition expression into an AST
+func (cp *ConditionParser) Parse(expression string) (ASTNode, error) {
+	expr, err := cp.lookup(expression)
+	if err != nil {
+		return nil, err
+	}
+	return expr.ast, nil
 }
 
 // Evaluate evaluates a condition expression against form data
 func (cp *ConditionParser) Evaluate(expression string, formData map[string]interface{}, currentPath []string) (bool, error) {
-	ast, err := cp.Parse(expression)
+	return cp.EvaluateEnv(expression, formData, currentPath, nil)
+}
+
+// EvaluateEnv evaluates a condition expression, resolving $ variables from env
+func (cp *ConditionParser) EvaluateEnv(expression string, formData map[string]interface{}, currentPath []string, env Env) (bool, error) {
+	expr, err := cp.lookup(expression)
 	if err != nil {
 		return false, err
 	}
 
-	evaluator := newEvaluator(formData, currentPath)
-	result := evaluator.evaluate(ast)
-
-	return isTruthy(result), nil
+	value, err := expr.EvalEnv(formData, currentPath, env)
+	if err != nil {
+		return false, err
+	}
+	return isTruthy(value), nil
 }
 
 // EvaluateValue evaluates an expression and returns the result value (for ternary expressions)
 // Returns the raw value instead of just a boolean
 func (cp *ConditionParser) EvaluateValue(expression string, formData map[string]interface{}, currentPath []string) (interface{}, error) {
-	ast, err := cp.Parse(expression)
+	expr, err := cp.lookup(expression)
 	if err != nil {
 		return nil, err
 	}
 
-	evaluator := newEvaluator(formData, currentPath)
-	return evaluator.evaluate(ast), nil
+	return expr.Eval(formData, currentPath)
 }
 
 // Lexer tokenizes condition expressions
@@ -78,6 +165,7 @@ type lexer struct {
 	position int
 	line     int
 	column   int
+	previous TokenType // type of the last significant token
 }
 
 func newLexer(input string) *lexer {
@@ -99,6 +187,7 @@ func (l *lexer) tokenize() ([]Token, error) {
 		}
 		if token.Type != TokenWhitespace {
 			tokens = append(tokens, token)
+			l.previous = token.Type
 		}
 	}
 
@@ -125,6 +214,12 @@ func (l *lexer) nextToken() (Token, error) {
 	if l.matchString("||") {
 		return l.makeToken(TokenOr, "||", start), nil
 	}
+	if l.matchString("=~") {
+		return l.makeToken(TokenMatch, "=~", start), nil
+	}
+	if l.matchString("!~") {
+		return l.makeToken(TokenNotMatch, "!~", start), nil
+	}
 	if l.matchString("==") {
 		return l.makeToken(TokenEQ, "==", start), nil
 	}
@@ -167,6 +262,24 @@ func (l *lexer) nextToken() (Token, error) {
 		return l.makeToken(TokenAsterisk, "*", start), nil
 	}
 
+	// Arithmetic operators ('-' directly before a digit is a negative number
+	// unless it follows an operand, as in ".total -1")
+	if l.matchString("+") {
+		return l.makeToken(TokenPlus, "+", start), nil
+	}
+	if l.joinsWords() {
+		// An unquoted value such as a-b, which must be quoted, rather than a subtraction
+		l.advance()
+		return Token{Type: TokenInvalid, Value: "-", Position: TokenPosition{Start: start, End: l.position, Line: l.line, Column: startColumn}}, nil
+	}
+	if l.peek() == '-' && (!unicode.IsDigit(rune(l.peekNext())) || l.followsOperand()) {
+		l.advance()
+		return l.makeToken(TokenMinus, "-", start), nil
+	}
+	if l.matchString("/") {
+		return l.makeToken(TokenSlash, "/", start), nil
+	}
+
 	// Parentheses, brackets, and comma
 	if l.matchString("(") {
 		return l.makeToken(TokenLParen, "(", start), nil
@@ -200,8 +313,20 @@ func (l *lexer) nextToken() (Token, error) {
 		return l.readIdentifier()
 	}
 
-	// Numbers (including negative)
+	// Variables: $name
+	if l.peek() == '$' && (unicode.IsLetter(rune(l.peekNext())) || l.peekNext() == '_') {
+		l.advance()
+		for !l.isAtEnd() && (unicode.IsLetter(rune(l.peek())) || unicode.IsDigit(rune(l.peek())) || l.peek() == '_') {
+			l.advance()
+		}
+		return l.makeToken(TokenVariable, l.input[start+1:l.position], start), nil
+	}
+
+	// Dates, durations and numbers (including negative)
 	if unicode.IsDigit(rune(l.peek())) || (l.peek() == '-' && unicode.IsDigit(rune(l.peekNext()))) {
+		if token, ok := l.lexDateOrDuration(); ok {
+			return token, nil
+		}
 		return l.readNumber()
 	}
 
@@ -227,7 +352,7 @@ func (l *lexer) readString() (Token, error) {
 	}
 
 	if l.isAtEnd() {
-		return Token{}, fmt.Errorf("unterminated string at position %d", start)
+		return Token{}, &ParseError{Code: CodeUnterminatedString, Message: "unterminated string", Start: start, End: l.position}
 	}
 
 	l.advance() // closing quote
@@ -295,6 +420,31 @@ func (l *lexer) readNumber() (Token, error) {
 	}, nil
 }
 
+// joinsWords reports whether the next character is a '-' between two words without
+// spaces, as in a-b or .shipping-address
+func (l *lexer) joinsWords() bool {
+	if l.peek() != '-' || l.position == 0 || l.previous != TokenIdentifier && l.previous != TokenVariable {
+		return false
+	}
+	before, after := rune(l.input[l.position-1]), rune(l.peekNext())
+	return isWordChar(before) && (unicode.IsLetter(after) || after == '_')
+}
+
+// isWordChar reports whether a character can be part of an identifier
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// followsOperand reports whether the last significant token ends an operand
+func (l *lexer) followsOperand() bool {
+	switch l.previous {
+	case TokenIdentifier, TokenVariable, TokenNumber, TokenDate, TokenDuration, TokenString, TokenBoolean, TokenNull, TokenRParen, TokenRBracket:
+		return true
+	default:
+		return false
+	}
+}
+
 func (l *lexer) matchWhitespace() bool {
 	if !l.isAtEnd() && unicode.IsSpace(rune(l.peek())) {
 		for !l.isAtEnd() && unicode.IsSpace(rune(l.peek())) {
@@ -315,8 +465,7 @@ func (l *lexer) matchString(s string) bool {
 		if s == "not in" || s == "not  in" {
 			// Match "not" followed by whitespace and "in"
 			remaining := l.input[l.position:]
-			re := regexp.MustCompile(`^not\s+in\b`)
-			match := re.FindString(remaining)
+			match := notInPattern.FindString(remaining)
 			if match != "" {
 				l.position += len(match)
 				l.column += len(match)
@@ -371,6 +520,10 @@ func (l *lexer) isAtEnd() bool {
 type parser struct {
 	tokens  []Token
 	current int
+	errors  []*ParseError // errors recovered from so far, in source order
+	limits  Limits
+	depth   int                       // nesting of recursive productions, bounded by limits.MaxDepth
+	regexes map[string]*regexp.Regexp // compiled literal patterns of =~ and !~
 }
 
 func newParser(tokens []Token) *parser {
@@ -380,17 +533,26 @@ func newParser(tokens []Token) *parser {
 	}
 }
 
+// parse parses the whole token stream. Syntax errors in operands of && and || are
+// collected; if there is more than one, the error is a ParseErrors.
 func (p *parser) parse() (ASTNode, error) {
 	expr, err := p.parseTernaryExpression()
-	if err != nil {
+	if parseErr, ok := err.(*ParseError); ok {
+		p.errors = append(p.errors, parseErr)
+	} else if err != nil {
 		return nil, err
+	} else if !p.isAtEnd() {
+		p.errors = append(p.errors, p.errorAtCurrent(CodeUnexpectedToken, "unexpected token: "+p.peek().Value))
 	}
 
-	if !p.isAtEnd() {
-		return nil, fmt.Errorf("unexpected token: %s", p.peek().Value)
+	switch len(p.errors) {
+	case 0:
+		return expr, nil
+	case 1:
+		return nil, p.errors[0]
+	default:
+		return nil, ParseErrors(p.errors)
 	}
-
-	return expr, nil
 }
 
 // parseTernaryExpression parses: or_expression [ "?" ternary_expression ":" ternary_expression ]
@@ -401,16 +563,16 @@ func (p *parser) parseTernaryExpression() (ASTNode, error) {
 	}
 
 	if p.match(TokenQuestion) {
-		trueValue, err := p.parseTernaryExpression()
+		trueValue, err := p.nest(p.parseTernaryExpression)
 		if err != nil {
 			return nil, err
 		}
 
 		if !p.match(TokenColon) {
-			return nil, fmt.Errorf("expected ':' in ternary expression at position %d", p.peek().Position.Start)
+			return nil, p.errorAtCurrent(CodeMissingDelimiter, "expected ':' in ternary expression")
 		}
 
-		falseValue, err := p.parseTernaryExpression()
+		falseValue, err := p.nest(p.parseTernaryExpression)
 		if err != nil {
 			return nil, err
 		}
@@ -420,8 +582,8 @@ func (p *parser) parseTernaryExpression() (ASTNode, error) {
 			TrueValue:  trueValue,
 			FalseValue: falseValue,
 			Position: ASTPosition{
-				Start: condition.getPosition().Start,
-				End:   falseValue.getPosition().End,
+				Start: condition.GetPosition().Start,
+				End:   falseValue.GetPosition().End,
 			},
 		}, nil
 	}
@@ -445,8 +607,8 @@ func (p *parser) parseOrExpression() (ASTNode, error) {
 			Left:     left,
 			Right:    right,
 			Position: ASTPosition{
-				Start: left.getPosition().Start,
-				End:   right.getPosition().End,
+				Start: left.GetPosition().Start,
+				End:   right.GetPosition().End,
 			},
 		}
 	}
@@ -455,13 +617,13 @@ func (p *parser) parseOrExpression() (ASTNode, error) {
 }
 
 func (p *parser) parseAndExpression() (ASTNode, error) {
-	left, err := p.parseNotExpression()
+	left, err := p.parseOperand(p.parseNotExpression)
 	if err != nil {
 		return nil, err
 	}
 
 	for p.match(TokenAnd) {
-		right, err := p.parseNotExpression()
+		right, err := p.parseOperand(p.parseNotExpression)
 		if err != nil {
 			return nil, err
 		}
@@ -470,8 +632,8 @@ func (p *parser) parseAndExpression() (ASTNode, error) {
 			Left:     left,
 			Right:    right,
 			Position: ASTPosition{
-				Start: left.getPosition().Start,
-				End:   right.getPosition().End,
+				Start: left.GetPosition().Start,
+				End:   right.GetPosition().End,
 			},
 		}
 	}
@@ -482,7 +644,7 @@ func (p *parser) parseAndExpression() (ASTNode, error) {
 func (p *parser) parseNotExpression() (ASTNode, error) {
 	if p.match(TokenNot) {
 		startPos := p.previous().Position.Start
-		operand, err := p.parseNotExpression()
+		operand, err := p.nest(p.parseNotExpression)
 		if err != nil {
 			return nil, err
 		}
@@ -491,7 +653,7 @@ func (p *parser) parseNotExpression() (ASTNode, error) {
 			Operand:  operand,
 			Position: ASTPosition{
 				Start: startPos,
-				End:   operand.getPosition().End,
+				End:   operand.GetPosition().End,
 			},
 		}, nil
 	}
@@ -500,7 +662,7 @@ func (p *parser) parseNotExpression() (ASTNode, error) {
 }
 
 func (p *parser) parseComparison() (ASTNode, error) {
-	left, err := p.parsePrimary()
+	left, err := p.parseAdditive(p.parsePrimary)
 	if err != nil {
 		return nil, err
 	}
@@ -512,33 +674,32 @@ func (p *parser) parseComparison() (ASTNode, error) {
 		if err != nil {
 			return nil, err
 		}
-		endPos := left.getPosition().End
-		if len(list) > 0 {
-			endPos = list[len(list)-1].getPosition().End
-		}
 		return &InNode{
 			Negated:  negated,
 			Value:    left,
 			List:     list,
-			Position: ASTPosition{Start: left.getPosition().Start, End: endPos},
+			Position: ASTPosition{Start: left.GetPosition().Start, End: p.previous().Position.End},
 		}, nil
 	}
 
-	// Comparison operators
-	if p.match(TokenEQ, TokenNE, TokenGT, TokenGE, TokenLT, TokenLE) {
-		operator := p.operatorFromToken(p.previous().Type)
+	// Comparison, string (contains, startsWith, =~, ...) and set (intersects, subsetOf) operators
+	if p.match(TokenEQ, TokenNE, TokenGT, TokenGE, TokenLT, TokenLE, TokenMatch, TokenNotMatch) || p.matchWordOperator() {
+		operator := p.operatorFromToken(p.previous())
 		// Use parseComparisonValue to handle unquoted identifiers as strings
-		right, err := p.parseComparisonValue()
+		right, err := p.parseAdditive(p.parseComparisonValue)
 		if err != nil {
 			return nil, err
 		}
+		if regexErr := p.checkRegex(operator, right); regexErr != nil {
+			return nil, regexErr
+		}
 		return &BinaryNode{
 			Operator: operator,
 			Left:     left,
 			Right:    right,
 			Position: ASTPosition{
-				Start: left.getPosition().Start,
-				End:   right.getPosition().End,
+				Start: left.GetPosition().Start,
+				End:   right.GetPosition().End,
 			},
 		}, nil
 	}
@@ -546,6 +707,62 @@ func (p *parser) parseComparison() (ASTNode, error) {
 	return left, nil
 }
 
+// parseAdditive parses: multiplicative ( ( "+" | "-" ) multiplicative )*
+// The first operand is parsed with the given function so that the right side of a
+// comparison keeps treating unquoted identifiers as strings.
+func (p *parser) parseAdditive(operand func() (ASTNode, error)) (ASTNode, error) {
+	left, err := p.parseMultiplicative(operand)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.match(TokenPlus, TokenMinus) {
+		operator := p.previous().Value
+		right, err := p.parseMultiplicative(p.parsePrimary)
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryNode{
+			Operator: operator,
+			Left:     left,
+			Right:    right,
+			Position: ASTPosition{
+				Start: left.GetPosition().Start,
+				End:   right.GetPosition().End,
+			},
+		}
+	}
+
+	return left, nil
+}
+
+// parseMultiplicative parses: primary ( ( "*" | "/" ) primary )*
+func (p *parser) parseMultiplicative(operand func() (ASTNode, error)) (ASTNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.match(TokenAsterisk, TokenSlash) {
+		operator := p.previous().Value
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		left = &BinaryNode{
+			Operator: operator,
+			Left:     left,
+			Right:    right,
+			Position: ASTPosition{
+				Start: left.GetPosition().Start,
+				End:   right.GetPosition().End,
+			},
+		}
+	}
+
+	return left, nil
+}
+
 // parseComparisonValue parses the right side of a comparison operator
 // Unquoted identifiers without trailing dots are treated as string literals
 func (p *parser) parseComparisonValue() (ASTNode, error) {
@@ -573,11 +790,32 @@ func (p *parser) parseComparisonValue() (ASTNode, error) {
 	return p.parsePrimary()
 }
 
+// matchWordOperator consumes a word operator such as contains or intersects.
+// The words are only operators after an operand, so fields may still use them as names.
+func (p *parser) matchWordOperator() bool {
+	if p.check(TokenIdentifier) && (stringOperators[p.peek().Value] || setOperators[p.peek().Value]) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+// parseValueList parses the list of an in operator: bare values (a, b, c) or an
+// array literal ([a, b, c]), whose values become the list
 func (p *parser) parseValueList() ([]ASTNode, error) {
-	var values []ASTNode
+	if p.check(TokenLBracket) {
+		array, err := p.parseArray()
+		if err != nil {
+			return nil, err
+		}
+		return array.Elements, nil
+	}
+	return p.parseValues()
+}
 
-	// Check for bracket-enclosed list syntax: [US, CA, UK]
-	hasBrackets := p.match(TokenLBracket)
+// parseValues parses: value ( "," value )*
+func (p *parser) parseValues() ([]ASTNode, error) {
+	var values []ASTNode
 
 	for {
 		value, err := p.parseValue()
@@ -585,27 +823,39 @@ func (p *parser) parseValueList() ([]ASTNode, error) {
 			return nil, err
 		}
 		values = append(values, value)
+		if p.limits.MaxListSize > 0 && len(values) > p.limits.MaxListSize {
+			pos := value.GetPosition()
+			return nil, &ParseError{
+				Code:    CodeLimitExceeded,
+				Message: fmt.Sprintf("in list has more than MaxListSize of %d values", p.limits.MaxListSize),
+				Start:   pos.Start,
+				End:     pos.End,
+			}
+		}
 
 		if !p.match(TokenComma) {
 			break
 		}
 	}
 
-	// Consume closing bracket if we had an opening one
-	if hasBrackets {
-		if !p.match(TokenRBracket) {
-			return nil, fmt.Errorf("expected closing bracket ] at position %d", p.peek().Position.Start)
-		}
-	}
-
 	return values, nil
 }
 
 func (p *parser) parseValue() (ASTNode, error) {
-	if p.match(TokenString, TokenNumber, TokenBoolean, TokenNull) {
+	if p.match(TokenString, TokenNumber, TokenDate, TokenDuration, TokenBoolean, TokenNull) {
 		return p.parseLiteral(p.previous()), nil
 	}
 
+	// A variable or field holding an array contributes its elements: 'admin' in $user.roles.
+	// Only relative paths are paths here: a bare value with dots, such as example.com,
+	// is an error rather than an absolute path, so it must be quoted.
+	if p.check(TokenVariable) {
+		return p.parseVariable()
+	}
+	if p.check(TokenDot) || p.check(TokenDotDot) {
+		return p.parsePath()
+	}
+
 	// Unquoted identifier treated as string
 	if p.match(TokenIdentifier) {
 		token := p.previous()
@@ -616,36 +866,76 @@ func (p *parser) parseValue() (ASTNode, error) {
 		}, nil
 	}
 
-	return nil, fmt.Errorf("expected value at position %d", p.peek().Position.Start)
+	return nil, p.errorAtCurrent(CodeExpectedValue, "expected value")
 }
 
 func (p *parser) parsePrimary() (ASTNode, error) {
 	// Grouped expression
 	if p.match(TokenLParen) {
-		expr, err := p.parseOrExpression()
+		expr, err := p.nest(p.parseOrExpression)
 		if err != nil {
 			return nil, err
 		}
 		if !p.match(TokenRParen) {
-			return nil, fmt.Errorf("expected ')' at position %d", p.peek().Position.Start)
+			return nil, p.errorAtCurrent(CodeMissingDelimiter, "expected ')'")
 		}
 		return &GroupNode{
 			Expression: expr,
-			Position:   *expr.getPosition(),
+			Position:   *expr.GetPosition(),
 		}, nil
 	}
 
+	// Quantifier: any(...), all(...), none(...)
+	if p.check(TokenIdentifier) && isQuantifier(p.peek().Value) && p.checkNext(TokenLParen) {
+		return p.parseQuantifier()
+	}
+
+	// Variable
+	if p.check(TokenVariable) {
+		return p.parseVariable()
+	}
+
+	// Array literal
+	if p.check(TokenLBracket) {
+		return p.parseArray()
+	}
+
 	// Path (relative or absolute)
 	if p.check(TokenDot) || p.check(TokenDotDot) || p.check(TokenIdentifier) {
 		return p.parsePath()
 	}
 
 	// Literal
-	if p.match(TokenString, TokenNumber, TokenBoolean, TokenNull) {
+	if p.match(TokenString, TokenNumber, TokenDate, TokenDuration, TokenBoolean, TokenNull) {
 		return p.parseLiteral(p.previous()), nil
 	}
 
-	return nil, fmt.Errorf("expected expression at position %d", p.peek().Position.Start)
+	return nil, p.errorAtCurrent(CodeExpectedExpression, "expected expression")
+}
+
+// parseQuantifier parses: ( "any" | "all" | "none" ) "(" or_expression ")"
+func (p *parser) parseQuantifier() (ASTNode, error) {
+	token := p.advance()
+	p.advance() // consume '('
+
+	expr, err := p.nest(p.parseOrExpression)
+	if err != nil {
+		return nil, err
+	}
+	if !p.match(TokenRParen) {
+		return nil, p.errorAtCurrent(CodeMissingDelimiter, "expected ')'")
+	}
+
+	return &QuantifierNode{
+		Quantifier: token.Value,
+		Expression: expr,
+		Position:   ASTPosition{Start: token.Position.Start, End: p.previous().Position.End},
+	}, nil
+}
+
+// isQuantifier reports whether an identifier names a wildcard quantifier
+func isQuantifier(name string) bool {
+	return name == "any" || name == "all" || name == "none"
 }
 
 func (p *parser) parsePath() (ASTNode, error) {
@@ -676,7 +966,7 @@ func (p *parser) parsePath() (ASTNode, error) {
 	} else {
 		// For absolute paths, first identifier is required
 		if !p.check(TokenIdentifier) {
-			return nil, fmt.Errorf("expected identifier at position %d", p.peek().Position.Start)
+			return nil, p.errorAtCurrent(CodeExpectedPathSegment, "expected identifier")
 		}
 		segment, err := p.parsePathSegment()
 		if err != nil {
@@ -702,6 +992,29 @@ func (p *parser) parsePath() (ASTNode, error) {
 	}, nil
 }
 
+// parseVariable parses: "$" identifier { "." ( identifier | number ) }
+func (p *parser) parseVariable() (ASTNode, error) {
+	token := p.advance()
+
+	var segments []PathSegment
+	for p.match(TokenDot) {
+		if p.check(TokenAsterisk) {
+			return nil, p.errorAtCurrent(CodeExpectedPathSegment, "wildcards are not supported in variables")
+		}
+		segment, err := p.parsePathSegment()
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+
+	return &VariableNode{
+		Name:     token.Value,
+		Segments: segments,
+		Position: ASTPosition{Start: token.Position.Start, End: p.previous().Position.End},
+	}, nil
+}
+
 func (p *parser) parsePathSegment() (PathSegment, error) {
 	if p.match(TokenAsterisk) {
 		return PathSegment{Type: "wildcard"}, nil
@@ -715,7 +1028,7 @@ func (p *parser) parsePathSegment() (PathSegment, error) {
 		return PathSegment{Type: "identifier", Value: p.previous().Value}, nil
 	}
 
-	return PathSegment{}, fmt.Errorf("expected path segment at position %d", p.peek().Position.Start)
+	return PathSegment{}, p.errorAtCurrent(CodeExpectedPathSegment, "expected path segment")
 }
 
 func (p *parser) parseLiteral(token Token) ASTNode {
@@ -729,6 +1042,10 @@ func (p *parser) parseLiteral(token Token) ASTNode {
 		valueType = "boolean"
 	case TokenNull:
 		valueType = "null"
+	case TokenDate:
+		valueType = "date"
+	case TokenDuration:
+		valueType = "duration"
 	}
 
 	return &LiteralNode{
@@ -738,8 +1055,8 @@ func (p *parser) parseLiteral(token Token) ASTNode {
 	}
 }
 
-func (p *parser) operatorFromToken(t TokenType) string {
-	switch t {
+func (p *parser) operatorFromToken(token Token) string {
+	switch token.Type {
 	case TokenEQ:
 		return "=="
 	case TokenNE:
@@ -752,11 +1069,80 @@ func (p *parser) operatorFromToken(t TokenType) string {
 		return "<"
 	case TokenLE:
 		return "<="
+	case TokenMatch:
+		return "=~"
+	case TokenNotMatch:
+		return "!~"
+	case TokenIdentifier:
+		return token.Value // word operators
 	default:
 		return ""
 	}
 }
 
+// errorAtCurrent creates a ParseError located at the current token.
+// A character the lexer could not tokenize is reported as such, whatever was expected.
+func (p *parser) errorAtCurrent(code ParseErrorCode, message string) *ParseError {
+	token := p.peek()
+	if token.Type == TokenInvalid {
+		code, message = CodeInvalidCharacter, fmt.Sprintf("unexpected character %q", token.Value)
+		if token.Value == "-" {
+			message += "; quote a value containing -, or put spaces around - to subtract"
+		}
+	}
+	return &ParseError{Code: code, Message: message, Start: token.Position.Start, End: token.Position.End}
+}
+
+// nest parses a nested construct (group, quantifier, negation or ternary branch).
+// Every recursive production goes through it, so the recursion depth stays within
+// MaxDepth whatever the input.
+func (p *parser) nest(parse func() (ASTNode, error)) (ASTNode, error) {
+	if p.limits.MaxDepth > 0 && p.depth >= p.limits.MaxDepth {
+		return nil, p.errorAtCurrent(CodeLimitExceeded, fmt.Sprintf("expression is nested deeper than MaxDepth of %d", p.limits.MaxDepth))
+	}
+	p.depth++
+	defer func() { p.depth-- }()
+	return parse()
+}
+
+// parseOperand parses an operand of && or ||. On a syntax error the error is recorded
+// and the parser skips to the next && or || at the same nesting level, so that a
+// single pass reports every malformed operand.
+func (p *parser) parseOperand(operand func() (ASTNode, error)) (ASTNode, error) {
+	start := p.peek().Position.Start
+	node, err := operand()
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		return node, err
+	}
+
+	p.errors = append(p.errors, parseErr)
+	p.synchronize()
+	return &LiteralNode{ValueType: "null", Position: ASTPosition{Start: start, End: p.peek().Position.Start}}, nil
+}
+
+// synchronize skips tokens up to the next && or || outside of the parentheses opened
+// since the error, or to a closing parenthesis of an enclosing group
+func (p *parser) synchronize() {
+	depth := 0
+	for !p.isAtEnd() {
+		switch p.peek().Type {
+		case TokenAnd, TokenOr:
+			if depth == 0 {
+				return
+			}
+		case TokenLParen:
+			depth++
+		case TokenRParen:
+			if depth == 0 {
+				return
+			}
+			depth--
+		}
+		p.advance()
+	}
+}
+
 func (p *parser) match(types ...TokenType) bool {
 	for _, t := range types {
 		if p.check(t) {
@@ -774,6 +1160,14 @@ func (p *parser) check(t TokenType) bool {
 	return p.peek().Type == t
 }
 
+// checkNext checks the type of the token after the current one without consuming anything
+func (p *parser) checkNext(t TokenType) bool {
+	if p.current+1 >= len(p.tokens) {
+		return false
+	}
+	return p.tokens[p.current+1].Type == t
+}
+
 func (p *parser) advance() Token {
 	if !p.isAtEnd() {
 		p.current++
@@ -797,6 +1191,16 @@ func (p *parser) isAtEnd() bool {
 type evaluator struct {
 	formData    map[string]interface{}
 	currentPath []string
+	anchors     [][]string // concrete paths bound by enclosing quantifiers (innermost last)
+	tracer      *tracer    // records an evaluation trace when set
+	env         Env
+	now         time.Time                  // clock of the built-in variables, fixed on first use
+	loc         *time.Location             // evaluation timezone, resolved on first use
+	fieldType   func(path []string) string // spec type of the field at a path, if known
+	regexes     map[string]*regexp.Regexp  // compiled literal patterns of the expression
+	limits      Limits
+	expansions  int   // array elements visited through wildcards so far
+	err         error // set when a limit stopped the evaluation
 }
 
 func newEvaluator(formData map[string]interface{}, currentPath []string) *evaluator {
@@ -807,6 +1211,17 @@ func newEvaluator(formData map[string]interface{}, currentPath []string) *evalua
 }
 
 func (e *evaluator) evaluate(node ASTNode) interface{} {
+	if e.err != nil {
+		return nil
+	}
+	if e.tracer != nil {
+		return e.tracer.record(e, node)
+	}
+	return e.evaluateNode(node)
+}
+
+// evaluateNode dispatches evaluation on the node type
+func (e *evaluator) evaluateNode(node ASTNode) interface{} {
 	switch n := node.(type) {
 	case *BinaryNode:
 		return e.evaluateBinary(n)
@@ -818,15 +1233,86 @@ func (e *evaluator) evaluate(node ASTNode) interface{} {
 		return e.evaluateTernary(n)
 	case *PathNode:
 		return e.evaluatePath(n)
+	case *VariableNode:
+		return e.evaluateVariable(n)
 	case *LiteralNode:
-		return n.Value
+		return e.evaluateLiteral(n)
+	case *ArrayNode:
+		return e.evaluateArray(n)
 	case *GroupNode:
 		return e.evaluate(n.Expression)
+	case *QuantifierNode:
+		return e.evaluateQuantifier(n)
 	default:
 		return nil
 	}
 }
 
+// evaluateQuantifier evaluates the inner expression once per element matched by its
+// wildcard path. The path with the most wildcards defines the iteration domain; every
+// other wildcard path sharing its array prefix resolves to the same element.
+func (e *evaluator) evaluateQuantifier(node *QuantifierNode) bool {
+	var domain []string
+	wildcards := 0
+	Inspect(node.Expression, func(n ASTNode) bool {
+		switch pn := n.(type) {
+		case *QuantifierNode:
+			return false // nested quantifiers bind their own wildcards
+		case *PathNode:
+			path := e.resolvePath(pn)
+			if count := countWildcards(path); count > wildcards {
+				domain = path
+				wildcards = count
+			}
+		}
+		return true
+	})
+
+	// Without a wildcard there is exactly one element: the expression itself
+	if domain == nil {
+		result := isTruthy(e.evaluate(node.Expression))
+		if node.Quantifier == "none" {
+			return !result
+		}
+		return result
+	}
+
+	result := node.Quantifier != "any" // an empty domain: any is false, all and none hold vacuously
+	e.eachWildcardPath(domain, func(anchor []string) bool {
+		e.anchors = append(e.anchors, anchor)
+		matched := isTruthy(e.evaluate(node.Expression))
+		e.anchors = e.anchors[:len(e.anchors)-1]
+		if e.err != nil {
+			result = false
+			return false
+		}
+
+		switch node.Quantifier {
+		case "any":
+			if matched {
+				e.shortCircuit()
+				result = true
+				return false
+			}
+		case "all":
+			if !matched {
+				e.shortCircuit()
+				result = false
+				return false
+			}
+		case "none":
+			if matched {
+				e.shortCircuit()
+				result = false
+				return false
+			}
+		}
+		return true
+	})
+
+	return result
+}
+
 // evaluateTernary evaluates a ternary expression and returns the value
 func (e *evaluator) evaluateTernary(node *TernaryNode) interface{} {
 	condition := e.evaluate(node.Condition)
@@ -842,6 +1328,7 @@ func (e *evaluator) evaluateBinary(node *BinaryNode) interface{} {
 	// Short-circuit evaluation
 	if node.Operator == "&&" {
 		if !isTruthy(left) {
+			e.shortCircuit()
 			return false
 		}
 		return isTruthy(e.evaluate(node.Right))
@@ -849,12 +1336,18 @@ func (e *evaluator) evaluateBinary(node *BinaryNode) interface{} {
 
 	if node.Operator == "||" {
 		if isTruthy(left) {
+			e.shortCircuit()
 			return true
 		}
 		return isTruthy(e.evaluate(node.Right))
 	}
 
 	right := e.evaluate(node.Right)
+	if (isTemporal(left) || isTemporal(right)) && !isStringOperator(node.Operator) && !setOperators[node.Operator] {
+		left, right = coerceTemporal(left, right, e.location())
+	} else if node.Operator == "-" {
+		left, right = e.coerceDates(left, right)
+	}
 
 	switch node.Operator {
 	case "==":
@@ -869,7 +1362,15 @@ func (e *evaluator) evaluateBinary(node *BinaryNode) interface{} {
 		return compare(left, right) < 0
 	case "<=":
 		return compare(left, right) <= 0
+	case "+", "-", "*", "/":
+		return arithmetic(node.Operator, left, right)
 	default:
+		if isStringOperator(node.Operator) {
+			return e.evaluateStringOperator(node.Operator, left, right)
+		}
+		if setOperators[node.Operator] {
+			return evaluateSetOperator(node.Operator, left, right)
+		}
 		return nil
 	}
 }
@@ -885,12 +1386,21 @@ func (e *evaluator) evaluateUnary(node *UnaryNode) interface{} {
 	}
 }
 
+// evaluateIn checks membership in the list. An item that evaluates to an array (a
+// field, a variable such as $user.roles or an array literal) contributes its
+// elements instead of itself. An array value, such as the selection of a multichoice
+// field, is in the list if any of its elements is.
 func (e *evaluator) evaluateIn(node *InNode) bool {
 	value := e.evaluate(node.Value)
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
 	for _, item := range node.List {
-		listValue := e.evaluate(item)
-		if isEqual(value, listValue) {
-			return !node.Negated
+		for _, element := range appendValues(nil, e.evaluate(item)) {
+			if containsValue(values, element) {
+				return !node.Negated
+			}
 		}
 	}
 	return node.Negated
@@ -898,7 +1408,7 @@ func (e *evaluator) evaluateIn(node *InNode) bool {
 
 func (e *evaluator) evaluatePath(node *PathNode) interface{} {
 	path := e.resolvePath(node)
-	return e.getValueByPath(path)
+	return e.temporalField(path, e.getValueByPath(path))
 }
 
 func (e *evaluator) resolvePath(node *PathNode) []string {
@@ -989,24 +1499,31 @@ func (e *evaluator) getValueWithWildcard(path []string, wildcardIndex int) inter
 	arrayPath := path[:wildcardIndex]
 	remainingPath := path[wildcardIndex+1:]
 
-	// Try to find the current array index from currentPath
-	// The arrayPath should match a prefix of currentPath
-	if len(e.currentPath) > len(arrayPath) && e.pathPrefixEquals(arrayPath, e.currentPath) {
-		// Check if there's a numeric index at the wildcard position
-		idxStr := e.currentPath[len(arrayPath)]
-		if _, err := strconv.Atoi(idxStr); err == nil {
-			// Same array context: use current index
-			resolvedPath := append(append([]string{}, arrayPath...), idxStr)
-			resolvedPath = append(resolvedPath, remainingPath...)
-			return e.getValueByPath(resolvedPath)
-		}
+	if idxStr, ok := e.boundIndex(arrayPath); ok {
+		// Same array context: use bound index
+		resolvedPath := append(append([]string{}, arrayPath...), idxStr)
+		resolvedPath = append(resolvedPath, remainingPath...)
+		return e.getValueByPath(resolvedPath)
 	}
 
 	// Different array or no matching context: get from array (ANY strategy)
 	arrayData := e.getValueByPath(arrayPath)
 
-	// Handle object data (e.g., from multiple: "only" pattern)
+	// Handle object data: the items of a keyed collection, else a single object
+	// (e.g., from multiple: "only" pattern)
 	if obj, ok := arrayData.(map[string]interface{}); ok {
+		if keys, keyed := uniqueKeys(obj); keyed {
+			for _, key := range keys {
+				if !e.spend(1) {
+					return nil
+				}
+				resolvedPath := append(append([]string{}, arrayPath...), key)
+				if value := e.getValueByPath(append(resolvedPath, remainingPath...)); value != nil {
+					return value
+				}
+			}
+			return nil
+		}
 		// For objects, skip the wildcard and access remaining path directly
 		if len(remainingPath) > 0 {
 			return getNestedValue(obj, remainingPath)
@@ -1021,6 +1538,9 @@ func (e *evaluator) getValueWithWildcard(path []string, wildcardIndex int) inter
 
 	// Return first non-nil value
 	for i := range arr {
+		if !e.spend(1) {
+			return nil
+		}
 		resolvedPath := append(append([]string{}, arrayPath...), strconv.Itoa(i))
 		resolvedPath = append(resolvedPath, remainingPath...)
 		value := e.getValueByPath(resolvedPath)
@@ -1032,6 +1552,75 @@ func (e *evaluator) getValueWithWildcard(path []string, wildcardIndex int) inter
 	return nil
 }
 
+// eachWildcardPath calls fn with the concrete path of every array element matched by
+// the wildcards of path, in order, until fn returns false. The items of a keyed
+// collection are matched at their unique keys, in sorted order. Each element counts
+// against MaxExpansions. Reports whether the iteration ran to completion.
+func (e *evaluator) eachWildcardPath(path []string, fn func([]string) bool) bool {
+	wildcardIndex := -1
+	for i, segment := range path {
+		if segment == "*" {
+			wildcardIndex = i
+			break
+		}
+	}
+	if wildcardIndex == -1 {
+		return fn(path)
+	}
+
+	arrayPath := path[:wildcardIndex]
+	_, segments, ok := repeatableItems(e.getValueByPath(arrayPath))
+	if !ok {
+		return true
+	}
+
+	for _, segment := range segments {
+		if !e.spend(1) {
+			return false
+		}
+		expandedPath := append(append(append([]string{}, arrayPath...), segment), path[wildcardIndex+1:]...)
+		if !e.eachWildcardPath(expandedPath, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// boundIndex finds the array index or unique key bound to arrayPath by the enclosing
+// quantifiers (innermost first) and then by currentPath. The arrayPath should match a
+// prefix of the context.
+func (e *evaluator) boundIndex(arrayPath []string) (string, bool) {
+	for i := len(e.anchors) - 1; i >= -1; i-- {
+		context := e.currentPath
+		if i >= 0 {
+			context = e.anchors[i]
+		}
+		if len(context) > len(arrayPath) && e.pathPrefixEquals(arrayPath, context) {
+			// Check if there's an index or unique key at the wildcard position
+			idxStr := context[len(arrayPath)]
+			if IsItemSegment(idxStr) {
+				return idxStr, true
+			}
+		}
+	}
+	return "", false
+}
+
+// bindPath replaces the wildcards of a path with the indexes bound by boundIndex.
+// Wildcards without a binding are kept.
+func (e *evaluator) bindPath(path []string) []string {
+	bound := append([]string{}, path...)
+	for i, segment := range bound {
+		if segment != "*" {
+			continue
+		}
+		if idxStr, ok := e.boundIndex(bound[:i]); ok {
+			bound[i] = idxStr
+		}
+	}
+	return bound
+}
+
 // pathPrefixEquals checks if prefix matches the beginning of path
 func (e *evaluator) pathPrefixEquals(prefix, path []string) bool {
 	if len(prefix) > len(path) {
@@ -1059,6 +1648,17 @@ func (e *evaluator) pathEquals(path1, path2 []string) bool {
 
 // Helper functions for evaluation
 
+// countWildcards counts the wildcard segments in a path
+func countWildcards(path []string) int {
+	count := 0
+	for _, segment := range path {
+		if segment == "*" {
+			count++
+		}
+	}
+	return count
+}
+
 func isTruthy(value interface{}) bool {
 	if value == nil {
 		return false
@@ -1079,6 +1679,10 @@ func isTruthy(value interface{}) bool {
 		return v != "" && v != "0" && strings.ToLower(v) != "false"
 	case []interface{}:
 		return len(v) > 0
+	case time.Time:
+		return !v.IsZero()
+	case time.Duration:
+		return v != 0
 	default:
 		return true
 	}
@@ -1090,6 +1694,11 @@ func isEqual(a, b interface{}) bool {
 		return a == nil && b == nil
 	}
 
+	// Times and durations
+	if c, ok := compareTemporal(a, b); ok {
+		return c == 0
+	}
+
 	// Try numeric comparison
 	numA, okA := toFloat64(a)
 	numB, okB := toFloat64(b)
@@ -1110,6 +1719,10 @@ func isEqual(a, b interface{}) bool {
 }
 
 func compare(a, b interface{}) int {
+	if c, ok := compareTemporal(a, b); ok {
+		return c
+	}
+
 	numA, okA := toFloat64(a)
 	numB, okB := toFloat64(b)
 
@@ -1128,6 +1741,37 @@ func compare(a, b interface{}) int {
 	return strings.Compare(strA, strB)
 }
 
+// arithmetic applies an arithmetic operator to two numeric operands, or to times and
+// durations. Returns nil if the operands do not support the operator or on division by zero.
+func arithmetic(operator string, a, b interface{}) interface{} {
+	if isTemporal(a) || isTemporal(b) {
+		result, _ := temporalArithmetic(operator, a, b)
+		return result
+	}
+
+	numA, okA := toFloat64(a)
+	numB, okB := toFloat64(b)
+	if !okA || !okB {
+		return nil
+	}
+
+	switch operator {
+	case "+":
+		return numA + numB
+	case "-":
+		return numA - numB
+	case "*":
+		return numA * numB
+	case "/":
+		if numB == 0 {
+			return nil
+		}
+		return numA / numB
+	default:
+		return nil
+	}
+}
+
 func toBoolean(value interface{}) bool {
 	switch v := value.(type) {
 	case bool:
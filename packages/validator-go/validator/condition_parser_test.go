@@ -0,0 +1,813 @@
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestQuantifiers tests any/all/none over wildcard paths
+func TestQuantifiers(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"status": "paid", "qty": 2, "options": []interface{}{
+				map[string]interface{}{"sold_out": 0},
+			}},
+			map[string]interface{}{"status": "pending", "qty": 0, "options": []interface{}{
+				map[string]interface{}{"sold_out": 0},
+				map[string]interface{}{"sold_out": 1},
+			}},
+		},
+		"empty": []interface{}{},
+		"note":  "",
+	}
+
+	cases := []struct {
+		name        string
+		expression  string
+		currentPath []string
+		want        bool
+	}{
+		{"any matches", "any(items.*.status == 'paid')", []string{"note"}, true},
+		{"all fails", "all(items.*.status == 'paid')", []string{"note"}, false},
+		{"none fails", "none(items.*.status == 'paid')", []string{"note"}, false},
+		{"none holds", "none(items.*.status == 'refunded')", []string{"note"}, true},
+		{"relative path", "all(.items.*.qty >= 0)", []string{"note"}, true},
+		{"same element binding", "any(items.*.status == 'pending' && items.*.qty == 0)", []string{"note"}, true},
+		{"same element binding mismatch", "any(items.*.status == 'paid' && items.*.qty == 0)", []string{"note"}, false},
+		{"nested wildcard any", "any(items.*.options.*.sold_out == 1)", []string{"note"}, true},
+		{"nested wildcard all", "all(items.*.options.*.sold_out == 0)", []string{"note"}, false},
+		{"overrides current index", "any(items.*.status == 'paid')", []string{"items", "1", "status"}, true},
+		{"without quantifier uses current index", "items.*.status == 'paid'", []string{"items", "1", "status"}, false},
+		{"empty any", "any(empty.*.x == 1)", []string{"note"}, false},
+		{"empty all", "all(empty.*.x == 1)", []string{"note"}, true},
+		{"combined with logic", "!any(items.*.qty > 5) && all(items.*.qty < 5)", []string{"note"}, true},
+		{"field named any", ".any == 1", []string{"note"}, false},
+	}
+
+	cp := NewConditionParser()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := cp.Evaluate(tc.expression, data, tc.currentPath)
+			if err != nil {
+				t.Fatalf("Evaluate(%q) returned error: %v", tc.expression, err)
+			}
+			if got != tc.want {
+				t.Errorf("Evaluate(%q) = %t, want %t", tc.expression, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestCompile tests compiled expressions and the parser cache
+func TestCompile(t *testing.T) {
+	expr, err := Compile(".type == 1 ? 10 : 20")
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+	if got := expr.EvaluateValue(map[string]interface{}{"type": 1}, []string{"value"}); got != float64(10) {
+		t.Errorf("EvaluateValue = %v, want 10", got)
+	}
+	if expr.String() != ".type == 1 ? 10 : 20" {
+		t.Errorf("String() = %q", expr.String())
+	}
+
+	if _, err := Compile(".is_sale == "); err == nil {
+		t.Error("Expected Compile to fail for incomplete expression")
+	}
+
+	cp := NewConditionParser()
+	first, _ := cp.Compile(".a == 1")
+	second, _ := cp.Compile(".a == 1")
+	if first != second {
+		t.Error("Expected cached compile to return the same *Expression")
+	}
+}
+
+// TestConditionParserCacheBound tests that the compile cache keeps at most
+// maxCachedExpressions entries and that one-off evaluations are not cached
+func TestConditionParserCacheBound(t *testing.T) {
+	cp := NewConditionParser()
+	kept, _ := cp.Compile(".kept == 1")
+	for i := 0; i < maxCachedExpressions+100; i++ {
+		if i%1000 == 0 {
+			cp.Compile(".kept == 1") // Recently used, so never dropped
+		}
+		cp.Compile(".a == " + strconv.Itoa(i))
+	}
+	if len(cp.cache) != maxCachedExpressions || cp.recent.Len() != maxCachedExpressions {
+		t.Errorf("Expected %d cached expressions, got %d", maxCachedExpressions, len(cp.cache))
+	}
+	if again, _ := cp.Compile(".kept == 1"); again != kept {
+		t.Error("Expected a recently used expression to stay cached")
+	}
+	if _, ok := cp.cache[".a == 0"]; ok {
+		t.Error("Expected the least recently used expression to be dropped")
+	}
+
+	cp = NewConditionParser()
+	cp.Evaluate(".user == 'x' && .name =~ '^a+$'", map[string]interface{}{"user": "x", "name": "aa"}, nil)
+	cp.EvaluateValue(".x +", nil, nil)
+	cp.Parse(".y == 2")
+	cp.Explain(".z == 3", nil, nil)
+	if len(cp.cache) != 0 {
+		t.Errorf("Expected one-off expressions not to be cached, got %d", len(cp.cache))
+	}
+	if ok, err := cp.Evaluate(".name =~ '^a+$'", map[string]interface{}{"name": "aa"}, nil); !ok || err != nil {
+		t.Errorf("Expected an uncached pattern to match, got %v, %v", ok, err)
+	}
+}
+
+// TestNewValidatorPrecompilesConditions tests that spec conditions are compiled at construction time
+func TestNewValidatorPrecompilesConditions(t *testing.T) {
+	spec := Spec{
+		Fields: []Field{
+			{Name: "is_sale", Type: "checkbox"},
+			{Name: "items", Type: "group", Multiple: true, Fields: []Field{
+				{Name: "price", Type: "number", Rules: map[string]interface{}{
+					"required": "..is_sale == 1",
+					"min":      ".discount == 1 ? 100 : 0",
+				}},
+				{Name: "note", Type: "text", Required: ".price > 0"},
+			}},
+		},
+	}
+	v := NewValidator(spec)
+
+	for _, expression := range []string{"..is_sale == 1", ".discount == 1 ? 100 : 0", ".price > 0"} {
+		if _, ok := v.config.Load().conditionParser.cache[expression]; !ok {
+			t.Errorf("Expected %q to be compiled by NewValidator", expression)
+		}
+	}
+}
+
+// TestConcurrentConditionEvaluation tests that a shared validator can evaluate conditions concurrently
+func TestConcurrentConditionEvaluation(t *testing.T) {
+	spec := Spec{
+		Fields: []Field{
+			{Name: "items", Type: "group", Multiple: true, Fields: []Field{
+				{Name: "qty", Type: "number"},
+				{Name: "warehouse", Type: "text", Rules: map[string]interface{}{"required": ".qty > 0"}},
+			}},
+		},
+	}
+	v := NewValidator(spec)
+
+	items := make([]interface{}, 500)
+	for i := range items {
+		items[i] = map[string]interface{}{"qty": i % 2, "warehouse": ""}
+	}
+	data := map[string]interface{}{"items": items}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := v.Validate(data)
+			if len(result.Errors) != 250 {
+				t.Errorf("Expected 250 errors, got %d", len(result.Errors))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestExplain tests evaluation traces of conditions
+func TestExplain(t *testing.T) {
+	data := map[string]interface{}{
+		"is_sale": 1,
+		"order": map[string]interface{}{
+			"payment_type": "cash",
+			"card_number":  "",
+		},
+		"items": []interface{}{
+			map[string]interface{}{"qty": 0},
+			map[string]interface{}{"qty": 3},
+		},
+	}
+	cp := NewConditionParser()
+
+	trace, err := cp.Explain(".payment_type == 'card' && ..is_sale", data, []string{"order", "card_number"})
+	if err != nil {
+		t.Fatalf("Explain returned error: %v", err)
+	}
+	want := `.payment_type == 'card' && ..is_sale => false (short-circuited)
+  .payment_type == 'card' => false
+    .payment_type [order.payment_type] => "cash"
+    'card' => "card"`
+	if got := trace.String(); got != want {
+		t.Errorf("trace =\n%s\nwant\n%s", got, want)
+	}
+
+	trace, _ = cp.Explain("any(items.*.qty > 1)", data, []string{"is_sale"})
+	if !trace.ShortCircuited || trace.Result != true || trace.Operator != "any" {
+		t.Errorf("Expected short-circuited any => true, got %+v", trace)
+	}
+	if len(trace.Children) != 2 {
+		t.Fatalf("Expected one child per evaluated element, got %d", len(trace.Children))
+	}
+	if path := trace.Children[1].Children[0].Path; path != "items.1.qty" {
+		t.Errorf("Expected quantifier element path items.1.qty, got %q", path)
+	}
+
+	if _, err := cp.Explain(".a ==", data, nil); err == nil {
+		t.Error("Expected Explain to fail for an invalid expression")
+	}
+}
+
+// TestValidationErrorTrace tests that traces are attached to conditional failures only when enabled
+func TestValidationErrorTrace(t *testing.T) {
+	spec := Spec{
+		Fields: []Field{
+			{Name: "payment_type", Type: "text"},
+			{Name: "card_number", Type: "text", Required: ".payment_type == 'card'"},
+			{Name: "qty", Type: "number", Rules: map[string]interface{}{"min": ".payment_type == 'card' ? 10 : 1"}},
+			{Name: "name", Type: "text", Required: true},
+		},
+	}
+	data := map[string]interface{}{"payment_type": "card", "card_number": "", "qty": 5, "name": ""}
+
+	v := NewValidator(spec)
+	for _, e := range v.Validate(data).Errors {
+		if e.Trace != nil {
+			t.Errorf("Expected no trace when tracing is disabled, got one on %s", e.Field)
+		}
+	}
+
+	v.SetTraceConditions(true)
+	traces := map[string]*TraceNode{}
+	for _, e := range v.Validate(data).Errors {
+		traces[e.Field] = e.Trace
+	}
+	if len(traces) != 3 {
+		t.Fatalf("Expected 3 errors, got %d", len(traces))
+	}
+	if trace := traces["card_number"]; trace == nil || trace.Result != true || trace.Expression != ".payment_type == 'card'" {
+		t.Errorf("Expected required trace on card_number, got %+v", trace)
+	}
+	if trace := traces["qty"]; trace == nil || trace.Kind != "Ternary" || trace.Result != float64(10) {
+		t.Errorf("Expected ternary trace on qty, got %+v", trace)
+	}
+	if traces["name"] != nil {
+		t.Error("Expected no trace for an unconditional required")
+	}
+}
+
+// TestFormat tests canonical formatting and its round-trip stability
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{".a==1&&(.b in x,y)", ".a == 1 && .b in 'x', 'y'"},
+		{"(.a || .b) && .c", "(.a || .b) && .c"},
+		{".a || (.b && .c)", ".a || .b && .c"},
+		{"!(.a == 1)", "!.a == 1"},
+		{"!(.a && .b)", "!(.a && .b)"},
+		{"..is_sale == 1 ? (.price * 2) : 0", "..is_sale == 1 ? .price * 2 : 0"},
+		{".a - (.b - .c) > (.d + .e) * 2", ".a - (.b - .c) > (.d + .e) * 2"},
+		{".a - -1 == 0", ".a - -1 == 0"},
+		{".status not in [deleted, 'archived']", ".status not in 'deleted', 'archived'"},
+		{".a ==", ""},
+		{".name == 'it\\'s'", ".name == 'it\\'s'"},
+		{".x == (total)", ".x == (total)"},
+		{".x == (total + 1)", ".x == (total + 1)"},
+		{".x == total", ".x == 'total'"},
+		{"any(items.*.qty > 0 && items.*.qty < 5)", "any(items.*.qty > 0 && items.*.qty < 5)"},
+		{".a == 1 ? .b == 2 ? 'x' : 'y' : 'z'", ".a == 1 ? .b == 2 ? 'x' : 'y' : 'z'"},
+		{".rate == 0.50", ".rate == 0.5"},
+		{"(.a == null) != false", "(.a == null) != false"},
+		{".b in .a,x", ".b in .a, 'x'"},
+		{".b  intersects[x,'y,z' ,1]", ".b intersects ['x', 'y,z', 1]"},
+		{".b subsetOf []", ".b subsetOf []"},
+	}
+
+	for _, tc := range cases {
+		got, err := FormatExpression(tc.input)
+		if tc.want == "" {
+			if err == nil {
+				t.Errorf("FormatExpression(%q) = %q, expected an error", tc.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FormatExpression(%q) returned error: %v", tc.input, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("FormatExpression(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+
+		again, err := FormatExpression(got)
+		if err != nil || again != got {
+			t.Errorf("FormatExpression is not stable for %q: %q, %v", got, again, err)
+		}
+
+		original := MustCompile(tc.input).Evaluate(map[string]interface{}{"a": 1, "b": "x"}, []string{"f"})
+		formatted := MustCompile(got).Evaluate(map[string]interface{}{"a": 1, "b": "x"}, []string{"f"})
+		if original != formatted {
+			t.Errorf("Formatting %q changed its result", tc.input)
+		}
+	}
+}
+
+// TestASTJSON tests the JSON encoding of the AST against docs/CONDITION-PARSER.md
+func TestASTJSON(t *testing.T) {
+	ast := MustCompile(".payment_type == 'card' && items.*.amount >= 1000").AST()
+
+	data, err := json.Marshal(ast)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	want := `{"type":"Binary","operator":"&&",` +
+		`"left":{"type":"Binary","operator":"==",` +
+		`"left":{"type":"Path","relative":true,"levelsUp":0,"segments":[{"type":"identifier","value":"payment_type"}],"position":{"start":0,"end":13}},` +
+		`"right":{"type":"Literal","valueType":"string","value":"card","position":{"start":17,"end":23}},"position":{"start":0,"end":23}},` +
+		`"right":{"type":"Binary","operator":">=",` +
+		`"left":{"type":"Path","relative":false,"levelsUp":0,"segments":[{"type":"identifier","value":"items"},{"type":"wildcard"},{"type":"identifier","value":"amount"}],"position":{"start":27,"end":41}},` +
+		`"right":{"type":"Literal","valueType":"number","value":1000,"position":{"start":45,"end":49}},"position":{"start":27,"end":49}},` +
+		`"position":{"start":0,"end":49}}`
+	var got, expected interface{}
+	json.Unmarshal(data, &got)
+	json.Unmarshal([]byte(want), &expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Marshal =\n%s\nwant\n%s", data, want)
+	}
+
+	for _, expression := range []string{
+		".payment_type == 'card' && items.*.amount >= 1000",
+		"!(.a in 1, 'x', null) ? any(items.0.qty > 1) : .b - 2",
+		".tags intersects [vip, $role, .other] && .tag in .config.tags",
+	} {
+		data, _ := json.Marshal(MustCompile(expression).AST())
+		decoded, err := UnmarshalAST(data)
+		if err != nil {
+			t.Fatalf("UnmarshalAST(%s) returned error: %v", data, err)
+		}
+		again, _ := json.Marshal(decoded)
+		if string(again) != string(data) {
+			t.Errorf("JSON round trip changed the AST:\n%s\n%s", data, again)
+		}
+	}
+
+	if _, err := UnmarshalAST([]byte(`{"type":"Binary","operator":"=="}`)); err == nil {
+		t.Error("Expected UnmarshalAST to fail for a binary node without operands")
+	}
+	if _, err := UnmarshalAST([]byte(`{"type":"Call"}`)); err == nil {
+		t.Error("Expected UnmarshalAST to fail for an unknown node type")
+	}
+}
+
+// TestWalkAndRewrite tests the AST visitor and rewriting helpers
+func TestWalkAndRewrite(t *testing.T) {
+	expr := MustCompile(".old_name == 1 && (.other in a, b || .old_name > 2)")
+
+	var kinds []string
+	Inspect(expr.AST(), func(node ASTNode) bool {
+		kinds = append(kinds, node.NodeType())
+		return node.NodeType() != "In"
+	})
+	if got := strings.Join(kinds, ","); got != "Binary,Binary,Path,Literal,Group,Binary,In,Binary,Path,Literal" {
+		t.Errorf("Inspect visited %s", got)
+	}
+
+	renamed := Rewrite(expr.AST(), func(node ASTNode) ASTNode {
+		if path, ok := node.(*PathNode); ok && path.Segments[0].Value == "old_name" {
+			path.Segments[0].Value = "new_name"
+		}
+		return node
+	})
+	if got := Format(renamed); got != ".new_name == 1 && (.other in 'a', 'b' || .new_name > 2)" {
+		t.Errorf("Rewrite produced %q", got)
+	}
+	if got := Format(expr.AST()); got != ".old_name == 1 && (.other in 'a', 'b' || .old_name > 2)" {
+		t.Errorf("Rewrite modified the original AST: %q", got)
+	}
+}
+
+// TestParseErrors tests error codes, positions and recovery at && and || boundaries
+func TestParseErrors(t *testing.T) {
+	_, err := Compile(".a == && (.b > || .c) && .d in")
+	errs := ParseErrorsOf(err)
+	want := []ParseError{
+		{Code: CodeExpectedExpression, Message: "expected expression", Start: 6, End: 8, Line: 1, Column: 7},
+		{Code: CodeExpectedExpression, Message: "expected expression", Start: 15, End: 17, Line: 1, Column: 16},
+		{Code: CodeExpectedValue, Message: "expected value", Start: 30, End: 30, Line: 1, Column: 31},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d errors, got %v", len(want), err)
+	}
+	for i := range want {
+		if *errs[i] != want[i] {
+			t.Errorf("Error %d = %+v, want %+v", i, *errs[i], want[i])
+		}
+	}
+	var first *ParseError
+	if !errors.As(err, &first) || first != errs[0] {
+		t.Error("Expected errors.As to find the first *ParseError")
+	}
+
+	_, err = Compile(".a == 1 &&\n\t.b # 2")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Expected a *ParseError, got %v", err)
+	}
+	if parseErr.Code != CodeInvalidCharacter || parseErr.Line != 2 || parseErr.Column != 5 {
+		t.Errorf("Unexpected error %+v", parseErr)
+	}
+	if got := parseErr.Error(); got != `unexpected character "#" at line 2, column 5` {
+		t.Errorf("Error() = %q", got)
+	}
+	if got := parseErr.Caret(".a == 1 &&\n\t.b # 2"); got != "\t.b # 2\n\t   ^" {
+		t.Errorf("Caret() = %q", got)
+	}
+
+	// Unquoted values with dots are not paths in value lists
+	for _, expression := range []string{".host in example.com, foo", ".host in foo, example.com", ".host in [example.com]"} {
+		if _, err := Compile(expression); err == nil {
+			t.Errorf("Expected %q to be a parse error", expression)
+		}
+	}
+
+	// A - joining two words is an unquoted value, not a subtraction
+	for _, expression := range []string{".x == a-b", ".shipping-address == 1", "$a-b > 0", ".a == b1-c"} {
+		_, err := Compile(expression)
+		if !errors.As(err, &parseErr) || parseErr.Code != CodeInvalidCharacter || !strings.Contains(parseErr.Message, "quote a value containing -") {
+			t.Errorf("Expected %q to be an invalid character error, got %v", expression, err)
+		}
+	}
+	for _, expression := range []string{".x == a - b", ".x-1 > 0", ".x == 'a-b'"} {
+		if _, err := Compile(expression); err != nil {
+			t.Errorf("Expected %q to parse, got %v", expression, err)
+		}
+	}
+
+	if got := RenderCaret(".is_sale == 'y && .price", 12, 24); got != ".is_sale == 'y && .price\n            ^^^^^^^^^^^^" {
+		t.Errorf("RenderCaret = %q", got)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	cp := NewConditionParserWithLimits(Limits{MaxLength: 40, MaxDepth: 5, MaxListSize: 3, MaxExpansions: 10})
+
+	tests := []struct {
+		name       string
+		expression string
+		message    string
+	}{
+		{"length", ".a == '" + strings.Repeat("x", 40) + "'", "expression is 48 bytes long, exceeding MaxLength of 40"},
+		{"nesting", "((((((.a))))))", "expression is nested deeper than MaxDepth of 5"},
+		{"negation", "!!!!!!.a", "expression is nested deeper than MaxDepth of 5"},
+		{"chain", ".a + .b + .c + .d + .e > 1", "expression is nested deeper than MaxDepth of 5"},
+		{"list", ".a in 1, 2, 3, 4", "in list has more than MaxListSize of 3 values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cp.Compile(tt.expression)
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected a *ParseError, got %v", err)
+			}
+			if parseErr.Code != CodeLimitExceeded || parseErr.Message != tt.message {
+				t.Errorf("Unexpected error %+v", parseErr)
+			}
+		})
+	}
+
+	if _, err := cp.Compile("(((.a)))"); err != nil {
+		t.Errorf("Expected nesting within MaxDepth to compile: %v", err)
+	}
+	if _, err := Compile(strings.Repeat("(", 100000) + ".a" + strings.Repeat(")", 100000)); err == nil {
+		t.Error("Expected DefaultLimits to reject a huge expression")
+	}
+
+	// 4 x 4 nested items expand to more than 10 elements
+	items := make([]interface{}, 4)
+	for i := range items {
+		items[i] = map[string]interface{}{"tags": []interface{}{"a", "b", "c", "d"}}
+	}
+	data := map[string]interface{}{"items": items}
+
+	if ok, err := cp.Evaluate("any(.items.*.tags.* == 'b')", data, nil); err != nil || !ok {
+		t.Errorf("Expected early match within MaxExpansions, got %v, %v", ok, err)
+	}
+	_, err := cp.Evaluate("all(.items.*.tags.* != 'z')", data, nil)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "MaxExpansions" {
+		t.Fatalf("Expected a MaxExpansions LimitError, got %v", err)
+	}
+	if got := err.Error(); got != "expression evaluation exceeds MaxExpansions of 10" {
+		t.Errorf("Error() = %q", got)
+	}
+
+	expr, _ := cp.Compile("all(.items.*.tags.* != 'z')")
+	if expr.Evaluate(data, nil) {
+		t.Error("Expected an evaluation stopped by a limit to be false")
+	}
+}
+
+// TestValidatorConditionLimits tests that a condition stopped by MaxExpansions fails
+// its field instead of letting it pass
+func TestValidatorConditionLimits(t *testing.T) {
+	spec := Spec{Fields: []Field{
+		{Name: "items", Type: "group", Multiple: true, Fields: []Field{{Name: "x", Type: "number"}}},
+		{Name: "note", Type: "text", Rules: map[string]interface{}{"required": "any(items.*.x == 1)"}},
+		{Name: "qty", Type: "number", Rules: map[string]interface{}{"max": "any(items.*.x == 1) ? 1 : 100"}},
+	}}
+	items := make([]interface{}, 20)
+	for i := range items {
+		items[i] = map[string]interface{}{"x": 0}
+	}
+	items[15] = map[string]interface{}{"x": 1}
+	data := map[string]interface{}{"items": items, "qty": 5}
+
+	v := NewValidator(spec, WithConditionLimits(Limits{MaxExpansions: 10}))
+	var got []string
+	for _, e := range v.Validate(data).Errors {
+		got = append(got, e.Field+":"+e.Rule)
+		if e.Rule == "condition" && !strings.Contains(e.Message, "MaxExpansions of 10") {
+			t.Errorf("Expected the message to name the limit, got %q", e.Message)
+		}
+	}
+	if strings.Join(got, " ") != "note:condition qty:condition" {
+		t.Errorf("Expected condition errors, got %v", got)
+	}
+
+	got = nil
+	for _, e := range NewValidator(spec).Validate(data).Errors {
+		got = append(got, e.Field+":"+e.Rule)
+	}
+	if strings.Join(got, " ") != "note:required qty:max" {
+		t.Errorf("Expected the conditions to apply within the default limits, got %v", got)
+	}
+}
+
+func TestVariables(t *testing.T) {
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	timeNow = func() time.Time { return time.Date(2024, 5, 1, 23, 30, 0, 0, time.FixedZone("KST", 9*3600)) }
+
+	env := Env{
+		"mode":   "create",
+		"locale": "ko",
+		"user":   map[string]interface{}{"roles": []interface{}{"editor", "admin"}},
+	}
+	cp := NewConditionParser()
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{"$mode == 'create'", true},
+		{"$mode == create && $locale != en", true},
+		{"'admin' in $user.roles", true},
+		{"'owner' in $user.roles, owner", true},
+		{"'owner' not in $user.roles", true},
+		{"$user.roles.0 == 'editor'", true},
+		{"$undefined == null", true},
+		{"$today == '2024-05-01'", true},
+		{"$now == '2024-05-01T14:30:00Z'", true},
+		{"$mode == 'create' ? .a > 1 : .a > 5", true},
+	}
+	for _, tt := range tests {
+		got, err := cp.EvaluateEnv(tt.expression, map[string]interface{}{"a": 3}, nil, env)
+		if err != nil {
+			t.Errorf("EvaluateEnv(%q) returned error: %v", tt.expression, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("EvaluateEnv(%q) = %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+
+	if ok, _ := cp.EvaluateEnv("$today == '2000-01-01'", nil, nil, Env{"today": "2000-01-01"}); !ok {
+		t.Error("Expected the environment to override a built-in variable")
+	}
+	if got, _ := FormatExpression("$user.roles.0=='a'"); got != "$user.roles.0 == 'a'" {
+		t.Errorf("FormatExpression = %q", got)
+	}
+	if _, err := Compile("$user.*"); err == nil {
+		t.Error("Expected a wildcard in a variable to be rejected")
+	}
+
+	v := NewValidator(Spec{Fields: []Field{
+		{Name: "password", Type: "password", Required: "$mode == 'create'"},
+	}})
+	if result := v.ValidateWithEnv(map[string]interface{}{}, env); result.IsValid {
+		t.Error("Expected password to be required in create mode")
+	}
+	if result := v.ValidateWithEnv(map[string]interface{}{}, Env{"mode": "update"}); !result.IsValid {
+		t.Errorf("Expected password to be optional in update mode, got %v", result.Errors)
+	}
+}
+
+func TestDateTime(t *testing.T) {
+	spec := Spec{Fields: []Field{
+		{Name: "start", Type: "date"},
+		{Name: "end", Type: "date"},
+		{Name: "at", Type: "datetime"},
+		{Name: "open", Type: "time"},
+		{Name: "close", Type: "time"},
+		{Name: "note", Type: "text"},
+	}}
+	cp := NewSpecConditionParser(spec, DefaultLimits())
+
+	tests := []struct {
+		expression string
+		data       map[string]interface{}
+		want       bool
+	}{
+		{".end > .start", map[string]interface{}{"start": "2024-1-9", "end": "2024-01-10"}, true},
+		{".end - .start >= 7d", map[string]interface{}{"start": "2024-01-01", "end": "2024-01-08"}, true},
+		{".end - .start >= 7d", map[string]interface{}{"start": "2024-01-01", "end": "2024-01-07"}, false},
+		{".end >= .start + 2w", map[string]interface{}{"start": "2024-01-01", "end": "2024-01-15"}, true},
+		{".start < 2024-01-10 && .start >= '2024-1-1'", map[string]interface{}{"start": "2024-01-09"}, true},
+		{".at > 2024-05-01T00:00:00+09:00", map[string]interface{}{"at": "2024-04-30T15:30:00Z"}, true},
+		{".at == 2024-05-01T00:30:00+09:00", map[string]interface{}{"at": "2024-04-30 15:30:00Z"}, true},
+		{".close > .open", map[string]interface{}{"open": "9:05", "close": "10:00"}, true},
+		{".close - .open == 1.5h", map[string]interface{}{"open": "08:30", "close": "10:00"}, true},
+		{"'2024-01-10' - '2024-01-01' == 9d", nil, true},
+		{"(.end - .start) / 1d == 3", map[string]interface{}{"start": "2024-01-01", "end": "2024-01-04"}, true},
+		{".start == 'soon'", map[string]interface{}{"start": "soon"}, true},
+		{".note > .start", map[string]interface{}{"start": "2024-01-01", "note": "2024-1-2"}, true},
+	}
+	for _, tt := range tests {
+		got, err := cp.Evaluate(tt.expression, tt.data, nil)
+		if err != nil {
+			t.Errorf("Evaluate(%q) returned error: %v", tt.expression, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Evaluate(%q) with %v = %v, want %v", tt.expression, tt.data, got, tt.want)
+		}
+	}
+
+	if got, _ := FormatExpression(".end-.start>=7d && .start<2024-01-10"); got != ".end - .start >= 7d && .start < 2024-01-10" {
+		t.Errorf("FormatExpression = %q", got)
+	}
+
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	timeNow = func() time.Time { return time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC) }
+	if ok, _ := cp.EvaluateEnv("$today == 2024-05-01", nil, nil, nil); !ok {
+		t.Error("Expected $today to be the UTC date without a timezone")
+	}
+
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skip("timezone database is not available")
+	}
+	if ok, _ := cp.EvaluateEnv("$today == 2024-05-02 && $timezone == 'Asia/Seoul'", nil, nil, Env{"timezone": "Asia/Seoul"}); !ok {
+		t.Error("Expected $today to follow the timezone variable")
+	}
+	// 2024-03-10 is 23 hours long in New York
+	data := map[string]interface{}{"start": "2024-03-10", "end": "2024-03-11", "at": "2024-03-10T12:00:00"}
+	newYork := Env{"timezone": "America/New_York"}
+	for expression, want := range map[string]bool{
+		".end - .start == 1d":             true,
+		".at == 2024-03-10T16:00:00Z":     true,
+		".at > 2024-03-10T12:00:00-05:00": false,
+	} {
+		if got, _ := cp.EvaluateEnv(expression, data, nil, newYork); got != want {
+			t.Errorf("EvaluateEnv(%q) in New York = %v, want %v", expression, got, want)
+		}
+	}
+}
+
+// TestStringOperators tests contains, startsWith, endsWith, their case-insensitive
+// variants and regular expression matching
+func TestStringOperators(t *testing.T) {
+	cp := NewConditionParser()
+	data := map[string]interface{}{
+		"code":     "EU-1234",
+		"email":    "Kim@Example.com",
+		"tags":     []interface{}{"red", "Blue"},
+		"phone":    "010-1234",
+		"zip":      12345,
+		"contains": "x",
+		"empty":    nil,
+		"unit":     "\u212Aelvin", // Kelvin sign, which lowercases to a one-byte k
+	}
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{".code contains '-12'", true},
+		{".code startsWith 'EU'", true},
+		{".code startsWith 'eu'", false},
+		{".code istartsWith 'eu'", true},
+		{".email endsWith '.com'", true},
+		{".email iendsWith 'EXAMPLE.COM'", true},
+		{".email icontains 'kim@'", true},
+		{".unit istartsWith 'kel' && .unit iendsWith 'KELVIN' && .unit icontains 'kELV'", true},
+		{".tags contains red", true},
+		{".tags contains 'e'", false},
+		{".tags contains blue", false},
+		{".tags icontains blue", true},
+		{".tags startsWith 'Bl'", true},
+		{`.phone =~ '^\\d{3}-\\d{4}$'`, true}, // Backslashes are escaped in string literals
+		{`.phone =~ '^\d{3}'`, false},
+		{".code =~ '(?i)^eu-'", true},
+		{".code !~ '^EU'", false},
+		{".zip startsWith 123", true},
+		{".empty contains ''", false},
+		{".empty !~ 'x'", true},
+		{".missing endsWith ''", false},
+		{".contains == 'x' && .contains contains 'x'", true},
+		{".code contains .contains || .code endsWith '34'", true},
+	}
+	for _, tt := range tests {
+		got, err := cp.Evaluate(tt.expression, data, nil)
+		if err != nil {
+			t.Errorf("Evaluate(%q) returned error: %v", tt.expression, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Evaluate(%q) = %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+
+	_, err := Compile(".code =~ '[a-'")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.Code != CodeInvalidRegex {
+		t.Fatalf("Expected an invalid_regex error, got %v", err)
+	}
+	if parseErr.Start != 9 || parseErr.End != 14 {
+		t.Errorf("Expected the error at the pattern, got %d-%d", parseErr.Start, parseErr.End)
+	}
+
+	// A pattern from form data is compiled at evaluation; an invalid one never matches
+	if ok, _ := cp.Evaluate(".code =~ .pattern", map[string]interface{}{"code": "a", "pattern": "("}, nil); ok {
+		t.Error("Expected an invalid pattern from data not to match")
+	}
+
+	if got, _ := FormatExpression(".tags   icontains  'A'&&.code=~'^x'"); got != ".tags icontains 'A' && .code =~ '^x'" {
+		t.Errorf("FormatExpression = %q", got)
+	}
+}
+
+// TestArrays tests array literals, in lists read from fields and the set operators
+func TestArrays(t *testing.T) {
+	cp := NewConditionParser()
+	data := map[string]interface{}{
+		"category": "books",
+		"allowed":  []interface{}{"books", "music"},
+		"tags":     []interface{}{"sale", "new"},
+		"none":     []interface{}{},
+		"label":    "a,b",
+		"config":   map[string]interface{}{"tags": []interface{}{"new", "hot"}},
+	}
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{".category in .allowed", true},
+		{".category not in .allowed", false},
+		{".category in .config.tags", false},
+		{".category in .allowed, toys", true},
+		{".label in ['a,b', c]", true},
+		{".label in [a, b]", false},
+		{".category in [music, .category]", true},
+		{".tags in new, old", true},
+		{".tags not in old", true},
+		{".none in sale, new", false},
+		{".tags intersects [hot, new]", true},
+		{".tags intersects config.tags", true},
+		{".tags intersects []", false},
+		{".tags subsetOf [sale, new, hot]", true},
+		{".tags subsetOf config.tags", false},
+		{".none subsetOf [x]", true},
+		{".missing subsetOf [x]", true},
+		{".missing intersects [x]", false},
+		{".category subsetOf .allowed", true},
+		{"[1, 2] subsetOf [2, '1']", true},
+		{".intersects == null", true},
+	}
+	for _, tt := range tests {
+		got, err := cp.Evaluate(tt.expression, data, nil)
+		if err != nil {
+			t.Errorf("Evaluate(%q) returned error: %v", tt.expression, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Evaluate(%q) = %v, want %v", tt.expression, got, tt.want)
+		}
+	}
+
+	for _, expression := range []string{".a in [x, y", ".a intersects [x,]"} {
+		if _, err := Compile(expression); err == nil {
+			t.Errorf("Compile(%q) succeeded, expected an error", expression)
+		}
+	}
+
+	// The list of an array literal counts against MaxListSize
+	limited := NewConditionParserWithLimits(Limits{MaxListSize: 2})
+	if _, err := limited.Compile(".a intersects [1, 2, 3]"); err == nil {
+		t.Error("Expected an array literal to be limited by MaxListSize")
+	}
+}
@@ -0,0 +1,373 @@
+package validator
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Date and time support for conditions.
+//
+// Dates, datetimes and times are evaluated as time.Time and durations as
+// time.Duration. A value becomes temporal when it is a date or duration literal
+// (2024-01-10, 7d), the value of a date, datetime, time or month field when the
+// parser knows the spec (NewSpecConditionParser), or the other operand of a
+// comparison or subtraction with a temporal value. Dates without a UTC offset are
+// read in the evaluation timezone: the "timezone" variable of the environment
+// (an IANA name such as "Asia/Seoul"), or UTC.
+
+// dateLiteralPattern matches a date literal: an ISO 8601 date with an optional time
+// and UTC offset
+var dateLiteralPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(T\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:\d{2})?)?`)
+
+// durationUnits are the units of duration literals
+var durationUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+// durationPattern matches a duration literal such as 7d, 1.5h or -30m
+var durationPattern = regexp.MustCompile(`^(-?\d+(?:\.\d+)?)(ms|s|m|h|d|w)$`)
+
+// temporalFieldTypes are the spec field types whose values are read as times
+var temporalFieldTypes = map[string]bool{
+	"date":           true,
+	"datetime":       true,
+	"datetime-local": true,
+	"time":           true,
+	"month":          true,
+}
+
+// Layouts accepted for date strings, most specific first. Month and day may have
+// one or two digits, so "2024-1-9" is read as 2024-01-09.
+var (
+	zonedLayouts = []string{"2006-1-2T15:04:05Z07:00", "2006-1-2T15:04Z07:00"}
+	localLayouts = []string{"2006-1-2T15:04:05", "2006-1-2T15:04", "2006-1-2", "2006-1", "15:04:05", "15:04"}
+)
+
+// parseDateTime reads an ISO 8601 style date, datetime, month or time of day.
+// Values without a UTC offset are read in loc. A space may separate date and time.
+func parseDateTime(s string, loc *time.Location) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) < 4 {
+		return time.Time{}, false
+	}
+	if i := strings.IndexByte(s, ' '); i > 0 {
+		s = s[:i] + "T" + s[i+1:]
+	}
+
+	for _, layout := range zonedLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseDuration reads a duration literal (7d, 1.5h) or a Go duration (1h30m)
+func parseDuration(s string) (time.Duration, bool) {
+	if m := durationPattern.FindStringSubmatch(s); m != nil {
+		n, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(n * float64(durationUnits[m[2]])), true
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, true
+	}
+	return 0, false
+}
+
+// isTemporal reports whether a value is a time or a duration
+func isTemporal(value interface{}) bool {
+	switch value.(type) {
+	case time.Time, time.Duration:
+		return true
+	default:
+		return false
+	}
+}
+
+// coerceTemporal converts the string operand of a comparison with a time or duration
+// to the same kind, so that "2024-1-9" compares as a date with 2024-01-10. Operands
+// that cannot be converted are returned unchanged.
+func coerceTemporal(a, b interface{}, loc *time.Location) (interface{}, interface{}) {
+	switch {
+	case isTemporal(a) && !isTemporal(b):
+		return a, coerceLike(a, b, loc)
+	case isTemporal(b) && !isTemporal(a):
+		return coerceLike(b, a, loc), b
+	default:
+		return a, b
+	}
+}
+
+// coerceLike converts value to the kind of the temporal value like
+func coerceLike(like, value interface{}, loc *time.Location) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	switch like.(type) {
+	case time.Time:
+		if t, ok := parseDateTime(s, loc); ok {
+			return t
+		}
+	case time.Duration:
+		if d, ok := parseDuration(s); ok {
+			return d
+		}
+	}
+	return value
+}
+
+// coerceDates reads two strings as times when both are dates, so that subtracting
+// two date strings yields the duration between them
+func (e *evaluator) coerceDates(a, b interface{}) (interface{}, interface{}) {
+	sa, okA := a.(string)
+	sb, okB := b.(string)
+	if !okA || !okB {
+		return a, b
+	}
+	ta, okA := parseDateTime(sa, e.location())
+	tb, okB := parseDateTime(sb, e.location())
+	if !okA || !okB {
+		return a, b
+	}
+	return ta, tb
+}
+
+// compareTemporal compares two times or two durations
+func compareTemporal(a, b interface{}) (int, bool) {
+	switch x := a.(type) {
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), true
+		}
+	case time.Duration:
+		if y, ok := b.(time.Duration); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// temporalArithmetic applies an arithmetic operator to times and durations:
+//
+//	time - time          => duration
+//	time ± duration      => time
+//	duration ± duration  => duration
+//	duration * number    => duration (either order)
+//	duration / number    => duration
+//	duration / duration  => number
+//
+// Returns false if the operands are not one of these combinations.
+func temporalArithmetic(operator string, a, b interface{}) (interface{}, bool) {
+	switch x := a.(type) {
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			if operator == "-" {
+				return wallClockDiff(x, y), true
+			}
+		case time.Duration:
+			switch operator {
+			case "+":
+				return x.Add(y), true
+			case "-":
+				return x.Add(-y), true
+			}
+		}
+	case time.Duration:
+		switch y := b.(type) {
+		case time.Duration:
+			switch operator {
+			case "+":
+				return x + y, true
+			case "-":
+				return x - y, true
+			case "/":
+				if y == 0 {
+					return nil, true
+				}
+				return float64(x) / float64(y), true
+			}
+		default:
+			n, ok := toFloat64(b)
+			if !ok {
+				return nil, false
+			}
+			switch operator {
+			case "*":
+				return scaleDuration(x, n), true
+			case "/":
+				if n == 0 {
+					return nil, true
+				}
+				return scaleDuration(x, 1/n), true
+			}
+		}
+	default:
+		if d, ok := b.(time.Duration); ok && operator == "*" {
+			if n, ok := toFloat64(a); ok {
+				return scaleDuration(d, n), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// wallClockDiff returns a - b measured on the wall clock of a's timezone, so that
+// the difference between two dates is always a whole number of 24-hour days, even
+// across a daylight saving change
+func wallClockDiff(a, b time.Time) time.Duration {
+	b = b.In(a.Location())
+	wall := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	}
+	return wall(a).Sub(wall(b))
+}
+
+// scaleDuration multiplies a duration by a factor, saturating instead of overflowing
+func scaleDuration(d time.Duration, factor float64) time.Duration {
+	scaled := float64(d) * factor
+	switch {
+	case math.IsNaN(scaled):
+		return 0
+	case scaled >= math.MaxInt64:
+		return math.MaxInt64
+	case scaled <= math.MinInt64:
+		return math.MinInt64
+	}
+	return time.Duration(scaled)
+}
+
+// locations caches loaded timezones by name
+var locations sync.Map
+
+// loadLocation returns the timezone of a name, or UTC if it is unknown
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC // Not stored, so arbitrary names cannot grow the cache
+	}
+	locations.Store(name, loc)
+	return loc
+}
+
+// location returns the evaluation timezone, set by the "timezone" variable
+func (e *evaluator) location() *time.Location {
+	if e.loc == nil {
+		name, _ := e.env["timezone"].(string)
+		e.loc = loadLocation(name)
+	}
+	return e.loc
+}
+
+// evaluateLiteral returns the value of a literal; date and duration literals are
+// stored as their source text and read as time.Time and time.Duration
+func (e *evaluator) evaluateLiteral(node *LiteralNode) interface{} {
+	switch node.ValueType {
+	case "date":
+		if t, ok := parseDateTime(toString(node.Value), e.location()); ok {
+			return t
+		}
+		return nil
+	case "duration":
+		if d, ok := parseDuration(toString(node.Value)); ok {
+			return d
+		}
+		return nil
+	default:
+		return node.Value
+	}
+}
+
+// temporalField reads the string value of a date, datetime, time or month field as
+// a time. Other values, and strings that are not dates, are returned unchanged.
+func (e *evaluator) temporalField(path []string, value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok || e.fieldType == nil || !temporalFieldTypes[e.fieldType(path)] {
+		return value
+	}
+	if t, ok := parseDateTime(s, e.location()); ok {
+		return t
+	}
+	return value
+}
+
+// lexDateOrDuration reads a date or duration literal at the current position, if any
+func (l *lexer) lexDateOrDuration() (Token, bool) {
+	start := l.position
+	rest := l.input[start:]
+
+	if m := dateLiteralPattern.FindString(rest); m != "" && !isIdentifierByte(rest, len(m)) {
+		l.advanceBy(len(m))
+		return Token{Type: TokenDate, Value: m, Literal: m, Position: TokenPosition{Start: start, End: l.position}}, true
+	}
+
+	// A number immediately followed by a unit
+	i := 0
+	if i < len(rest) && rest[i] == '-' {
+		i++
+	}
+	digits := i
+	for i < len(rest) && (rest[i] >= '0' && rest[i] <= '9' || rest[i] == '.') {
+		i++
+	}
+	if i == digits {
+		return Token{}, false
+	}
+	for _, unit := range []string{"ms", "s", "m", "h", "d", "w"} {
+		if strings.HasPrefix(rest[i:], unit) && !isIdentifierByte(rest, i+len(unit)) {
+			literal := rest[:i+len(unit)]
+			if _, ok := parseDuration(literal); !ok {
+				return Token{}, false
+			}
+			l.advanceBy(len(literal))
+			return Token{Type: TokenDuration, Value: literal, Literal: literal, Position: TokenPosition{Start: start, End: l.position}}, true
+		}
+	}
+	return Token{}, false
+}
+
+// isIdentifierByte reports whether s[i] continues an identifier
+func isIdentifierByte(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// advanceBy consumes n bytes of input
+func (l *lexer) advanceBy(n int) {
+	for i := 0; i < n; i++ {
+		l.advance()
+	}
+}
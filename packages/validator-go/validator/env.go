@@ -0,0 +1,51 @@
+package validator
+
+import "time"
+
+// Env holds the variables a condition references with a $ prefix, such as $mode or
+// $user.roles. Values should have the types of decoded JSON: string, float64, bool,
+// nil, []interface{} and map[string]interface{}.
+type Env map[string]interface{}
+
+// builtinVariables are available in every condition unless the environment defines a
+// variable of the same name. They receive the current time in the evaluation timezone
+// (the "timezone" variable, or UTC); each evaluation reads the clock once, so $now
+// and $today always agree.
+var builtinVariables = map[string]func(now time.Time) interface{}{
+	"now":      func(now time.Time) interface{} { return now.Format(time.RFC3339) },  // "2024-05-01T09:30:00Z"
+	"today":    func(now time.Time) interface{} { return now.Format(time.DateOnly) }, // "2024-05-01"
+	"timezone": func(now time.Time) interface{} { return now.Location().String() },   // "UTC"
+}
+
+// timeNow is the clock of the built-in variables, replaced in tests
+var timeNow = time.Now
+
+// IsBuiltinVariable reports whether name is a built-in variable ($now, $today, $timezone)
+func IsBuiltinVariable(name string) bool {
+	_, ok := builtinVariables[name]
+	return ok
+}
+
+// variable returns the value of a variable, or nil if it is not defined
+func (e *evaluator) variable(name string) interface{} {
+	if value, ok := e.env[name]; ok {
+		return value
+	}
+	builtin, ok := builtinVariables[name]
+	if !ok {
+		return nil
+	}
+	if e.now.IsZero() {
+		e.now = timeNow()
+	}
+	return builtin(e.now.In(e.location()))
+}
+
+// evaluateVariable returns the value of a variable reference, following its segments
+func (e *evaluator) evaluateVariable(node *VariableNode) interface{} {
+	path := make([]string, len(node.Segments))
+	for i, seg := range node.Segments {
+		path[i] = seg.Value
+	}
+	return lookupValue(e.variable(node.Name), path)
+}
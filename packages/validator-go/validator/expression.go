@@ -0,0 +1,114 @@
+package validator
+
+import "regexp"
+
+// Expression is a compiled condition expression.
+// It is immutable and safe for concurrent use by multiple goroutines.
+type Expression struct {
+	source string
+	ast    ASTNode
+	limits Limits
+
+	regexes   map[string]*regexp.Regexp  // compiled literal patterns of =~ and !~
+	fieldType func(path []string) string // spec type of the field at a path, if known
+}
+
+// Compile parses a condition expression into a reusable compiled form, enforcing
+// DefaultLimits. Syntax errors are returned as a *ParseError, or as ParseErrors when
+// several operands of && and || are malformed.
+func Compile(expression string) (*Expression, error) {
+	return compile(expression, DefaultLimits())
+}
+
+// compile parses an expression under the given limits
+func compile(expression string, limits Limits) (*Expression, error) {
+	var err error
+	if lengthErr := limits.checkLength(expression); lengthErr != nil {
+		err = lengthErr
+	}
+
+	var ast ASTNode
+	var regexes map[string]*regexp.Regexp
+	if err == nil {
+		var tokens []Token
+		tokens, err = newLexer(expression).tokenize()
+		if err == nil {
+			p := newParser(tokens)
+			p.limits = limits
+			ast, err = p.parse()
+			regexes = p.regexes
+		}
+	}
+	if err == nil {
+		if depthErr := limits.checkDepth(ast); depthErr != nil {
+			err = depthErr
+		}
+	}
+	if err != nil {
+		for _, parseErr := range ParseErrorsOf(err) {
+			parseErr.locate(expression)
+		}
+		return nil, err
+	}
+
+	return &Expression{source: expression, ast: ast, limits: limits, regexes: regexes}, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed
+func MustCompile(expression string) *Expression {
+	expr, err := Compile(expression)
+	if err != nil {
+		panic("validator: Compile(" + expression + "): " + err.Error())
+	}
+	return expr
+}
+
+// String returns the source text of the expression
+func (x *Expression) String() string {
+	return x.source
+}
+
+// AST returns the root node of the parsed expression.
+// The tree is shared by every user of the expression and must not be modified;
+// use Rewrite to derive a changed copy.
+func (x *Expression) AST() ASTNode {
+	return x.ast
+}
+
+// Evaluate evaluates the expression against form data and returns its truthiness
+func (x *Expression) Evaluate(formData map[string]interface{}, currentPath []string) bool {
+	return isTruthy(x.EvaluateValue(formData, currentPath))
+}
+
+// EvaluateValue evaluates the expression and returns the raw result value.
+// An evaluation stopped by a limit yields nil; use Eval to tell the two apart.
+func (x *Expression) EvaluateValue(formData map[string]interface{}, currentPath []string) interface{} {
+	value, _ := x.Eval(formData, currentPath)
+	return value
+}
+
+// Eval evaluates the expression and returns the raw result value, or a *LimitError
+// if the evaluation visited more array elements than MaxExpansions allows
+func (x *Expression) Eval(formData map[string]interface{}, currentPath []string) (interface{}, error) {
+	return x.EvalEnv(formData, currentPath, nil)
+}
+
+// EvalEnv is like Eval, resolving $ variables from env
+func (x *Expression) EvalEnv(formData map[string]interface{}, currentPath []string, env Env) (interface{}, error) {
+	evaluator := x.newEvaluator(formData, currentPath)
+	evaluator.env = env
+	value := evaluator.evaluate(x.ast)
+	if evaluator.err != nil {
+		return nil, evaluator.err
+	}
+	return value, nil
+}
+
+// newEvaluator creates an evaluator bound by the limits the expression was compiled with
+func (x *Expression) newEvaluator(formData map[string]interface{}, currentPath []string) *evaluator {
+	evaluator := newEvaluator(formData, currentPath)
+	evaluator.limits = x.limits
+	evaluator.fieldType = x.fieldType
+	evaluator.regexes = x.regexes
+	return evaluator
+}
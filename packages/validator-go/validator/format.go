@@ -0,0 +1,234 @@
+package validator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Operator precedence levels, lowest first, following the grammar in docs/CONDITION-PARSER.md
+const (
+	precTernary = iota + 1
+	precOr
+	precAnd
+	precNot
+	precComparison
+	precAdditive
+	precMultiplicative
+	precPrimary
+)
+
+// Format prints an AST as canonical expression text.
+// Operators are separated by single spaces, strings are single-quoted and
+// parentheses are only emitted where precedence requires them, so that parsing
+// the output yields the same tree and formatting it again yields the same text.
+func Format(node ASTNode) string {
+	var sb strings.Builder
+	formatNode(&sb, node, precTernary)
+	return sb.String()
+}
+
+// FormatExpression parses an expression and returns its canonical text
+func FormatExpression(expression string) (string, error) {
+	expr, err := Compile(expression)
+	if err != nil {
+		return "", err
+	}
+	return Format(expr.ast), nil
+}
+
+// formatNode writes a node, wrapping it in parentheses if it binds looser than minPrec
+func formatNode(sb *strings.Builder, node ASTNode, minPrec int) {
+	// Groups only carry the source parentheses; precedence decides whether they are needed
+	if group, ok := node.(*GroupNode); ok {
+		formatNode(sb, group.Expression, minPrec)
+		return
+	}
+
+	if precedence(node) < minPrec {
+		sb.WriteByte('(')
+		defer sb.WriteByte(')')
+	}
+
+	switch n := node.(type) {
+	case *TernaryNode:
+		formatNode(sb, n.Condition, precOr)
+		sb.WriteString(" ? ")
+		formatNode(sb, n.TrueValue, precTernary)
+		sb.WriteString(" : ")
+		formatNode(sb, n.FalseValue, precTernary)
+	case *BinaryNode:
+		left, right := operandPrecedence(n.Operator)
+		formatNode(sb, n.Left, left)
+		sb.WriteString(" " + n.Operator + " ")
+		if right == precAdditive && isBareIdentifier(leftmostOperand(n.Right)) {
+			// A bare identifier right of a comparison is read as a string; keep it a path
+			right = precPrimary + 1
+		}
+		formatNode(sb, n.Right, right)
+	case *UnaryNode:
+		sb.WriteString(n.Operator)
+		formatNode(sb, n.Operand, precNot)
+	case *InNode:
+		formatNode(sb, n.Value, precAdditive)
+		if n.Negated {
+			sb.WriteString(" not in ")
+		} else {
+			sb.WriteString(" in ")
+		}
+		for i, item := range n.List {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			formatNode(sb, item, precPrimary)
+		}
+	case *ArrayNode:
+		sb.WriteByte('[')
+		for i, element := range n.Elements {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			formatNode(sb, element, precPrimary)
+		}
+		sb.WriteByte(']')
+	case *QuantifierNode:
+		sb.WriteString(n.Quantifier + "(")
+		formatNode(sb, n.Expression, precOr)
+		sb.WriteString(")")
+	case *PathNode:
+		sb.WriteString(formatPath(n))
+	case *VariableNode:
+		sb.WriteString(formatVariable(n))
+	case *LiteralNode:
+		sb.WriteString(formatLiteral(n))
+	}
+}
+
+// precedence returns the binding strength of a node
+func precedence(node ASTNode) int {
+	switch n := node.(type) {
+	case *TernaryNode:
+		return precTernary
+	case *BinaryNode:
+		switch n.Operator {
+		case "||":
+			return precOr
+		case "&&":
+			return precAnd
+		case "+", "-":
+			return precAdditive
+		case "*", "/":
+			return precMultiplicative
+		default:
+			return precComparison
+		}
+	case *UnaryNode:
+		return precNot
+	case *InNode:
+		return precComparison
+	default:
+		return precPrimary
+	}
+}
+
+// operandPrecedence returns the minimum precedence of the left and right operands of a
+// binary operator. Operators are left-associative and comparisons do not chain.
+func operandPrecedence(operator string) (int, int) {
+	switch operator {
+	case "||":
+		return precOr, precAnd
+	case "&&":
+		return precAnd, precNot
+	case "+", "-":
+		return precAdditive, precMultiplicative
+	case "*", "/":
+		return precMultiplicative, precPrimary
+	default:
+		return precAdditive, precAdditive
+	}
+}
+
+// leftmostOperand returns the first operand of an arithmetic expression
+func leftmostOperand(node ASTNode) ASTNode {
+	for {
+		switch n := node.(type) {
+		case *GroupNode:
+			node = n.Expression
+		case *BinaryNode:
+			if precedence(n) < precAdditive {
+				return n
+			}
+			node = n.Left
+		default:
+			return node
+		}
+	}
+}
+
+// isBareIdentifier reports whether a node is an absolute path of a single identifier
+func isBareIdentifier(node ASTNode) bool {
+	path, ok := node.(*PathNode)
+	return ok && !path.Relative && len(path.Segments) == 1 && path.Segments[0].Type == "identifier"
+}
+
+// formatPath prints a path with its relative prefix
+func formatPath(node *PathNode) string {
+	var sb strings.Builder
+	if node.Relative {
+		sb.WriteString(strings.Repeat(".", node.LevelsUp+1))
+	}
+	for i, seg := range node.Segments {
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		if seg.Type == "wildcard" {
+			sb.WriteByte('*')
+		} else {
+			sb.WriteString(seg.Value)
+		}
+	}
+	return sb.String()
+}
+
+// formatVariable prints a variable reference with its $ prefix
+func formatVariable(node *VariableNode) string {
+	var sb strings.Builder
+	sb.WriteString("$" + node.Name)
+	for _, seg := range node.Segments {
+		sb.WriteString("." + seg.Value)
+	}
+	return sb.String()
+}
+
+// formatLiteral prints a literal value
+func formatLiteral(node *LiteralNode) string {
+	if node.ValueType == "date" || node.ValueType == "duration" {
+		return toString(node.Value)
+	}
+	switch v := node.Value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return quoteString(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// quoteString single-quotes a string, escaping quotes and backslashes
+func quoteString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\'' || s[i] == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+	sb.WriteByte('\'')
+	return sb.String()
+}
@@ -0,0 +1,314 @@
+package validator
+
+import (
+	"mime/multipart"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// maxFormIndex is the largest list index a form key may use. Larger indices are kept
+// as map keys.
+const maxFormIndex = 9999
+
+// formListSlack is how many missing items a list of indexed children may have. The
+// children of a node whose largest index leaves more gaps are kept in a map keyed by
+// index, so that the size of a list never follows an index chosen by the client: a
+// body of keys such as a0[9999]=1&a1[9999]=1 would otherwise allocate 10,000 slots
+// per key.
+const formListSlack = 16
+
+// DecodeForm converts the values of a URL-encoded form into the nested data Validate
+// takes. Keys use bracket notation: users[0][name] is the name of the first item of
+// users, each value of tags[] or users[][name] adds an item, and a unique key such as
+// files[__a1b2c3d4e5f6g__] keeps the item under that key in a map. Items missing
+// between indices are nil; indexed items with more than a few gaps between them are
+// kept in a map keyed by index instead of a list. The items of a spec field that
+// takes them, such as a repeatable group, always come out in a shape validation
+// checks: a map only if all its keys are unique keys, else a list, in which items
+// too sparse to keep their index are moved up in index order, followed by items
+// under other keys.
+//
+// A key gives a list of all its values if its spec field takes a list (a multiple
+// field, multichoice or tagify, or a checkbox with items), or if it has no spec field
+// and several values; otherwise it gives its last value. Values stay strings, which
+// the rules and conditions read as numbers where needed.
+func (s Spec) DecodeForm(values url.Values) map[string]interface{} {
+	root := &formNode{}
+	for _, key := range sortedKeys(values) {
+		s.insertFormValues(root, key, formValues(values[key]))
+	}
+	return root.fieldsData()
+}
+
+// DecodeMultipartForm converts a multipart form like DecodeForm, adding its files as
+// *multipart.FileHeader values, which the accept rule checks by file name and content
+// type. Files follow the same rules as values, so a file field with multiple set
+// gives a list of headers.
+func (s Spec) DecodeMultipartForm(form *multipart.Form) map[string]interface{} {
+	root := &formNode{}
+	for _, key := range sortedKeys(form.Value) {
+		s.insertFormValues(root, key, formValues(form.Value[key]))
+	}
+	for _, key := range sortedKeys(form.File) {
+		s.insertFormValues(root, key, formValues(form.File[key]))
+	}
+	return root.fieldsData()
+}
+
+// sortedKeys returns the keys of a form in sorted order, so that items added by []
+// keys come out in the same order on every call
+func sortedKeys[T any](m map[string][]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formValues returns the values of a form key as a list
+func formValues[T any](values []T) []interface{} {
+	list := make([]interface{}, len(values))
+	for i, v := range values {
+		list[i] = v
+	}
+	return list
+}
+
+// formNode is a value of a form being decoded: a leaf value, or the named and
+// indexed children of a group or list
+type formNode struct {
+	value      interface{}
+	fields     map[string]*formNode // Named children and unique keys
+	items      map[int]*formNode    // Children at list indices
+	appended   []*formNode          // Children added by []
+	holdsItems bool                 // Node of a spec field that takes items
+}
+
+// named returns the named child of a node, creating it if needed
+func (n *formNode) named(name string) *formNode {
+	if n.fields == nil {
+		n.fields = map[string]*formNode{}
+	}
+	if n.fields[name] == nil {
+		n.fields[name] = &formNode{}
+	}
+	return n.fields[name]
+}
+
+// child returns the child of a node at a key segment, creating it if needed;
+// an empty segment always adds a child
+func (n *formNode) child(segment string) *formNode {
+	if segment == "" {
+		c := &formNode{}
+		n.appended = append(n.appended, c)
+		return c
+	}
+	if i, ok := formIndex(segment); ok {
+		if n.items == nil {
+			n.items = map[int]*formNode{}
+		}
+		if n.items[i] == nil {
+			n.items[i] = &formNode{}
+		}
+		return n.items[i]
+	}
+	return n.named(segment)
+}
+
+// data converts a node into form data: the leaf value, a list for indexed children,
+// or a map for named ones or sparse indexed ones
+func (n *formNode) data() interface{} {
+	hasItems := len(n.items) > 0 || len(n.appended) > 0
+	size := 0
+	for i := range n.items {
+		if i+1 > size {
+			size = i + 1
+		}
+	}
+	switch {
+	case len(n.fields) == 0 && !hasItems:
+		return n.value
+	case n.holdsItems && (hasItems || !allUniqueKeys(n.fields)):
+		return n.itemsData(size)
+	case len(n.fields) == 0 && size <= len(n.items)+formListSlack:
+		list := make([]interface{}, size, size+len(n.appended))
+		for i, item := range n.items {
+			list[i] = item.data()
+		}
+		for _, item := range n.appended {
+			list = append(list, item.data())
+		}
+		return list
+	default:
+		return n.fieldsData()
+	}
+}
+
+// itemsData converts the children of the node of a field that takes items into a
+// list, so that validation sees every item: indexed children at their index, or in
+// index order if they are too sparse, then children added by [], then named children
+// in key order
+func (n *formNode) itemsData(size int) []interface{} {
+	var list []interface{}
+	if size <= len(n.items)+formListSlack {
+		list = make([]interface{}, size, size+len(n.appended)+len(n.fields))
+		for i, item := range n.items {
+			list[i] = item.data()
+		}
+	} else {
+		indices := make([]int, 0, len(n.items))
+		for i := range n.items {
+			indices = append(indices, i)
+		}
+		sort.Ints(indices)
+		list = make([]interface{}, 0, len(n.items)+len(n.appended)+len(n.fields))
+		for _, i := range indices {
+			list = append(list, n.items[i].data())
+		}
+	}
+	for _, item := range n.appended {
+		list = append(list, item.data())
+	}
+	keys := make([]string, 0, len(n.fields))
+	for key := range n.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		list = append(list, n.fields[key].data())
+	}
+	return list
+}
+
+// allUniqueKeys reports whether the named children of a node are all unique keys
+func allUniqueKeys(fields map[string]*formNode) bool {
+	for key := range fields {
+		if !IsUniqueKey(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldsData converts the children of a node into a map. Indexed children of a node
+// that also has named ones, or that are too sparse for a list, are kept under their
+// index, and children added by [] under an empty key.
+func (n *formNode) fieldsData() map[string]interface{} {
+	m := make(map[string]interface{}, len(n.fields)+len(n.items))
+	for key, field := range n.fields {
+		m[key] = field.data()
+	}
+	for i, item := range n.items {
+		m[strconv.Itoa(i)] = item.data()
+	}
+	if len(n.appended) > 0 {
+		list := make([]interface{}, len(n.appended))
+		for i, item := range n.appended {
+			list[i] = item.data()
+		}
+		m[""] = list
+	}
+	return m
+}
+
+// formIndex reads a list index segment: digits without leading zeros, up to
+// maxFormIndex
+func formIndex(segment string) (int, bool) {
+	if segment == "" || len(segment) > 1 && segment[0] == '0' {
+		return 0, false
+	}
+	i, err := strconv.Atoi(segment)
+	if err != nil || i < 0 || i > maxFormIndex || segment[0] == '+' {
+		return 0, false
+	}
+	return i, true
+}
+
+// splitFormKey splits a key in bracket notation into segments, with an empty
+// segment for []. A key that is not well formed is a single segment.
+func splitFormKey(key string) []string {
+	if segments, ok := parseBracketPath(key); ok {
+		return segments
+	}
+	return []string{key}
+}
+
+// insertFormValues adds the values of a form key to the tree at root
+func (s Spec) insertFormValues(root *formNode, key string, values []interface{}) {
+	segments := splitFormKey(key)
+
+	// Each value of a key with [] adds its own item
+	for _, segment := range segments[1:] {
+		if segment == "" {
+			for _, value := range values {
+				s.walkForm(root, segments).value = value
+			}
+			return
+		}
+	}
+
+	field := s.formFieldAt(segments)
+	switch {
+	case listField(field) || field == nil && len(values) > 1:
+		s.walkForm(root, segments).value = values
+	case len(values) > 0:
+		s.walkForm(root, segments).value = values[len(values)-1]
+	}
+}
+
+// walkForm returns the node at the segments of a key, creating the nodes on the way
+// and marking those of spec fields that take items. The first segment is always a
+// name.
+func (s Spec) walkForm(root *formNode, segments []string) *formNode {
+	node := root.named(segments[0])
+	for i := range segments {
+		if i > 0 {
+			node = node.child(segments[i])
+		}
+		if _, isIndex := formIndex(segments[i]); isIndex || segments[i] == "" || IsUniqueKey(segments[i]) || i == len(segments)-1 {
+			continue
+		}
+		if field := s.formFieldAt(segments[:i+1]); field != nil && (field.Multiple || listField(field)) {
+			node.holdsItems = true
+		}
+	}
+	return node
+}
+
+// formFieldAt returns the spec field of the segments of a form key, skipping list
+// indices, [] and unique keys, or nil if no field matches
+func (s Spec) formFieldAt(segments []string) *Field {
+	fields := s.Fields
+	var field *Field
+	for _, segment := range segments {
+		if _, isIndex := formIndex(segment); isIndex || segment == "" || IsUniqueKey(segment) {
+			continue
+		}
+		if field = findField(fields, segment); field == nil {
+			return nil
+		}
+		fields = field.Fields
+	}
+	return field
+}
+
+// listField reports whether the value of a field is a list of values
+func listField(field *Field) bool {
+	if field == nil || len(field.Fields) > 0 {
+		return false
+	}
+	if field.Multiple {
+		return true
+	}
+	switch field.Type {
+	case "multichoice", "tagify":
+		return true
+	case "checkbox":
+		_, hasItems := itemKeys(field)
+		return hasItems
+	default:
+		return false
+	}
+}
@@ -0,0 +1,206 @@
+package validator
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// formSpec is the spec the form decoding tests decode against
+var formSpec = Spec{Fields: []Field{
+	{Name: "name", Type: "text"},
+	{Name: "qty", Type: "number"},
+	{Name: "colors", Type: "multichoice"},
+	{Name: "sizes", Type: "checkbox", Items: map[string]interface{}{"s": "S", "m": "M"}},
+	{Name: "agree", Type: "checkbox"},
+	{Name: "users", Type: "group", Multiple: true, Fields: []Field{
+		{Name: "name", Type: "text", Rules: map[string]interface{}{"required": true}},
+		{Name: "roles", Type: "select", Multiple: true},
+	}},
+	{Name: "avatar", Type: "file", Rules: map[string]interface{}{"accept": ".png,.gif"}},
+	{Name: "gallery", Type: "file", Multiple: true, Rules: map[string]interface{}{"accept": "image/*"}},
+}}
+
+// TestDecodeForm tests decoding bracket-notation form keys into nested data
+func TestDecodeForm(t *testing.T) {
+	values := url.Values{
+		"name":                            {"first", "Kim"},
+		"qty":                             {"3"},
+		"colors":                          {"red"},
+		"sizes":                           {"s", "m"},
+		"agree":                           {"1"},
+		"users[0][name]":                  {"Ann"},
+		"users[0][roles][]":               {"admin", "editor"},
+		"users[2][name]":                  {""},
+		"users[2][roles]":                 {"viewer"},
+		"tags[]":                          {"a", "b"},
+		"unknown":                         {"1", "2"},
+		"rows[][sku]":                     {"A", "B"},
+		"files[__a1b2c3d4e5f6g__][title]": {"Cover"},
+		"files[__zzzzzzzzzzzzz__][title]": {"Back"},
+		"big[100000]":                     {"x"},
+		"sparse[9999]":                    {"x"},
+		"sparse[3]":                       {"y"},
+		"broken[key":                      {"y"},
+		"mixed[0]":                        {"zero"},
+		"mixed[label]":                    {"text"},
+		"deep[a][0][b][]":                 {"1"},
+		"leading[01]":                     {"kept as a key"},
+	}
+
+	got := formSpec.DecodeForm(values)
+	expected := map[string]interface{}{
+		"name":   "Kim",
+		"qty":    "3",
+		"colors": []interface{}{"red"},
+		"sizes":  []interface{}{"s", "m"},
+		"agree":  "1",
+		"users": []interface{}{
+			map[string]interface{}{"name": "Ann", "roles": []interface{}{"admin", "editor"}},
+			nil,
+			map[string]interface{}{"name": "", "roles": []interface{}{"viewer"}},
+		},
+		"tags":    []interface{}{"a", "b"},
+		"unknown": []interface{}{"1", "2"},
+		"rows":    []interface{}{map[string]interface{}{"sku": "A"}, map[string]interface{}{"sku": "B"}},
+		"files": map[string]interface{}{
+			"__a1b2c3d4e5f6g__": map[string]interface{}{"title": "Cover"},
+			"__zzzzzzzzzzzzz__": map[string]interface{}{"title": "Back"},
+		},
+		"big":        map[string]interface{}{"100000": "x"},
+		"sparse":     map[string]interface{}{"3": "y", "9999": "x"},
+		"broken[key": "y",
+		"mixed":      map[string]interface{}{"0": "zero", "label": "text"},
+		"deep":       map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": []interface{}{"1"}}}},
+		"leading":    map[string]interface{}{"01": "kept as a key"},
+	}
+	for key, value := range expected {
+		if !reflect.DeepEqual(got[key], value) {
+			t.Errorf("%s: expected %#v, got %#v", key, value, got[key])
+		}
+	}
+	if len(got) != len(expected) {
+		t.Errorf("Expected %d keys, got %d: %v", len(expected), len(got), got)
+	}
+
+	result := NewValidator(formSpec).Validate(got)
+	if len(result.Errors) != 1 || result.Errors[0].Field != "users.2.name" {
+		t.Errorf("Expected users.2.name to be required, got %v", result.Errors)
+	}
+}
+
+// TestDecodeFormSparseIndices tests that the size of decoded lists is bounded by the
+// values sent, not by the indices of their keys
+func TestDecodeFormSparseIndices(t *testing.T) {
+	values := url.Values{}
+	for i := 0; i < 2000; i++ {
+		values.Set("a"+strconv.Itoa(i)+"[9999]", "1")
+	}
+	values.Set("gaps[0]", "a")
+	values.Set("gaps[10]", "b")
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	before := stats.TotalAlloc
+	got := formSpec.DecodeForm(values)
+	runtime.ReadMemStats(&stats)
+	if grown := stats.TotalAlloc - before; grown > 16<<20 {
+		t.Errorf("Expected decoding to allocate in proportion to the form, allocated %d bytes", grown)
+	}
+
+	if _, isMap := got["a0"].(map[string]interface{}); !isMap {
+		t.Errorf("Expected a sparse index to give a map, got %T", got["a0"])
+	}
+	if list, ok := got["gaps"].([]interface{}); !ok || len(list) != 11 {
+		t.Errorf("Expected a list with a few gaps to stay a list, got %#v", got["gaps"])
+	}
+}
+
+// TestDecodeFormSparseItems tests that the items of a repeatable group are validated
+// however far apart their indices are
+func TestDecodeFormSparseItems(t *testing.T) {
+	v := NewValidator(formSpec)
+	for _, query := range []string{
+		"users[0][name]=&users[1][name]=Bo",
+		"users[0][name]=&users[100][name]=Bo",
+		"users[5000][name]=Bo&users[9][name]=",
+		"users[100000][name]=&users[0][name]=Bo",
+		"users[label][name]=&users[__a1b2c3d4e5f6g__][name]=Bo",
+		"users[][name]=Bo&users[7777][name]=",
+	} {
+		values, _ := url.ParseQuery(query)
+		data := formSpec.DecodeForm(values)
+		result := v.Validate(data)
+		if result.IsValid || len(result.Errors) != 1 || result.Errors[0].Rule != "required" {
+			t.Errorf("%s: expected the empty name to be required, got %v from %#v", query, result.Errors, data["users"])
+		}
+	}
+
+	values, _ := url.ParseQuery("users[9000][name]=b&users[20][name]=a&users[][name]=c")
+	want := []interface{}{
+		map[string]interface{}{"name": "a"},
+		map[string]interface{}{"name": "b"},
+		map[string]interface{}{"name": "c"},
+	}
+	if got := formSpec.DecodeForm(values)["users"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected sparse items in index order, got %#v", got)
+	}
+}
+
+// TestDecodeMultipartForm tests that file headers are kept for the file rules
+func TestDecodeMultipartForm(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("users[0][name]", "Ann")
+	addFile := func(field, filename, contentType string) {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="`+field+`"; filename="`+filename+`"`)
+		header.Set("Content-Type", contentType)
+		part, _ := writer.CreatePart(header)
+		part.Write([]byte("data"))
+	}
+	addFile("avatar", "me.jpg", "image/jpeg")
+	addFile("gallery", "a.png", "image/png")
+	addFile("gallery", "b.pdf", "application/pdf")
+	addFile("attachments[__a1b2c3d4e5f6g__]", "c.txt", "text/plain")
+	writer.Close()
+
+	request, _ := http.NewRequest("POST", "/", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := request.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+
+	data := formSpec.DecodeMultipartForm(request.MultipartForm)
+	if avatar, ok := data["avatar"].(*multipart.FileHeader); !ok || avatar.Filename != "me.jpg" {
+		t.Errorf("Expected the avatar file header, got %#v", data["avatar"])
+	}
+	if gallery, ok := data["gallery"].([]interface{}); !ok || len(gallery) != 2 {
+		t.Errorf("Expected two gallery file headers, got %#v", data["gallery"])
+	}
+	attachments, _ := data["attachments"].(map[string]interface{})
+	if fh, ok := attachments["__a1b2c3d4e5f6g__"].(*multipart.FileHeader); !ok || fh.Filename != "c.txt" {
+		t.Errorf("Expected an attachment under its unique key, got %#v", data["attachments"])
+	}
+
+	result := NewValidator(formSpec).Validate(data)
+	var fields []string
+	for _, e := range result.Errors {
+		fields = append(fields, e.Field+":"+e.Rule)
+	}
+	if !reflect.DeepEqual(fields, []string{"avatar:accept", "gallery:accept"}) {
+		t.Errorf("Expected accept errors for avatar and gallery, got %v", fields)
+	}
+
+	delete(data, "gallery")
+	data["avatar"].(*multipart.FileHeader).Filename = "me.PNG"
+	if result := NewValidator(formSpec).Validate(data); !result.IsValid {
+		t.Errorf("Expected a valid form, got %v", result.Errors)
+	}
+}
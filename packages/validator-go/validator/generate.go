@@ -0,0 +1,364 @@
+package validator
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// GenerateOptions configures the Go code written by Spec.GenerateGo
+type GenerateOptions struct {
+	Package  string // Package of the generated file; "forms" if empty
+	TypeName string // Name of the struct of the whole form; "Form" if empty
+}
+
+// buttonFieldTypes are the spec field types that hold no data
+var buttonFieldTypes = map[string]bool{
+	"submit": true,
+	"button": true,
+	"reset":  true,
+	"html":   true,
+}
+
+// initialisms are name parts written in capitals in Go identifiers
+var initialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "IP": true,
+	"JSON": true, "SKU": true, "URL": true, "UUID": true,
+}
+
+// GenerateGo writes Go types mirroring the spec, formatted like gofmt: a struct for
+// the form and for each group, a slice of structs for repeatable groups, and a string
+// type with constants for the options of fields with static items. Each field has a
+// json tag with its spec name, so values encode to the data Validate takes, and the
+// structs work with ValidateStruct and Bind.
+//
+// Numbers are float64, or int64 with a digits rule or a whole step; checkboxes are
+// bool; date, datetime, datetime-local, time and month fields are types embedding a
+// time.Time (Date, DateTime, LocalDateTime, TimeOfDay and Month) that encode to text
+// and JSON in the layout of the field type, such as 2006-01-02 for dates, so that
+// encoded values pass rules such as dateISO; multichoice fields and repeatable fields
+// are slices; other fields are strings. Fields that are not required unconditionally
+// are omitempty, and their numbers, bools, times and groups are pointers so that an
+// absent value differs from a zero one.
+func (s Spec) GenerateGo(opts GenerateOptions) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "forms"
+	}
+	if opts.TypeName == "" {
+		opts.TypeName = "Form"
+	}
+
+	g := &generator{names: map[string]bool{}, timeTypes: map[string]string{}}
+	g.writeStruct(opts.TypeName, "the form", s.Fields)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by validate gen. DO NOT EDIT.\n\npackage %s\n\n", opts.Package)
+	if len(g.timeTypes) > 0 {
+		out.WriteString("import (\n\t\"encoding/json\"\n\t\"time\"\n)\n\n")
+	}
+	for _, decl := range g.decls {
+		out.WriteString(decl)
+	}
+
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid Go code: %w", err)
+	}
+	return source, nil
+}
+
+// generator collects the declarations of the types of a spec
+type generator struct {
+	decls     []string          // Declarations, each type before the types of its fields
+	names     map[string]bool   // Type and constant names in use
+	timeTypes map[string]string // Declared type of each temporal field type
+}
+
+// reserve reserves a top-level name, numbering it if it is taken
+func (g *generator) reserve(name string) string {
+	unique := name
+	for i := 2; g.names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	g.names[unique] = true
+	return unique
+}
+
+// writeStruct declares a struct for the fields of a form or group and returns its name
+func (g *generator) writeStruct(name, of string, fields []Field) string {
+	name = g.reserve(name)
+	slot := len(g.decls)
+	g.decls = append(g.decls, "")
+
+	var body bytes.Buffer
+	goNames := map[string]bool{}
+	for i := range fields {
+		field := &fields[i]
+		if buttonFieldTypes[field.Type] || field.Name == "" {
+			continue
+		}
+
+		goName := goIdentifier(field.Name)
+		for base, n := goName, 2; goNames[goName]; n++ {
+			goName = base + strconv.Itoa(n)
+		}
+		goNames[goName] = true
+
+		goType, pointer := g.fieldType(name+goName, field)
+		required := isUnconditionallyRequired(field)
+		tag := field.Name
+		if !required {
+			tag += ",omitempty"
+			if pointer {
+				goType = "*" + goType
+			}
+		}
+
+		if field.Label != "" {
+			fmt.Fprintf(&body, "\t// %s\n", strings.Join(strings.Fields(field.Label), " "))
+		}
+		fmt.Fprintf(&body, "\t%s %s `json:%q`\n", goName, goType, tag)
+	}
+
+	g.decls[slot] = fmt.Sprintf("// %s holds the fields of %s\ntype %s struct {\n%s}\n\n", name, of, name, body.String())
+	return name
+}
+
+// fieldType returns the Go type of a field, declaring the types it needs under
+// typeName. pointer reports whether an optional field of the type needs a pointer
+// to tell an absent value from a zero one.
+func (g *generator) fieldType(typeName string, field *Field) (goType string, pointer bool) {
+	if len(field.Fields) > 0 {
+		of := "the " + field.Name + " group"
+		if field.Multiple {
+			of = "an item of the " + field.Name + " group"
+		}
+		name := g.writeStruct(typeName, of, field.Fields)
+		if field.Multiple {
+			return "[]" + name, false
+		}
+		return name, true
+	}
+
+	goType, pointer = g.valueType(typeName, field)
+	if field.Multiple && !strings.HasPrefix(goType, "[]") {
+		return "[]" + goType, false
+	}
+	return goType, pointer
+}
+
+// valueType returns the Go type of the value of a field without subfields
+func (g *generator) valueType(typeName string, field *Field) (string, bool) {
+	if keys, ok := itemKeys(field); ok {
+		enum := g.writeEnum(typeName, field.Name, keys, field.Items)
+		switch field.Type {
+		case "multichoice", "tagify", "checkbox":
+			return "[]" + enum, false
+		}
+		return enum, false
+	}
+
+	switch field.Type {
+	case "number", "range":
+		if isIntegerField(field) {
+			return "int64", true
+		}
+		return "float64", true
+	case "checkbox", "switch", "switcher":
+		return "bool", true
+	case "date", "datetime", "datetime-local", "time", "month":
+		return g.writeTime(field.Type), true
+	case "multichoice", "tagify":
+		return "[]string", false
+	default:
+		return "string", false
+	}
+}
+
+// writeEnum declares a string type with a constant for each option of a field and
+// returns its name
+func (g *generator) writeEnum(name, of string, keys []string, items map[string]interface{}) string {
+	name = g.reserve(name)
+
+	labels := map[string]string{}
+	for key, label := range items {
+		if group, ok := label.(map[string]interface{}); ok {
+			for groupKey, groupLabel := range group {
+				labels[groupKey] = fmt.Sprint(groupLabel)
+			}
+			continue
+		}
+		labels[key] = fmt.Sprint(label)
+	}
+
+	var decl strings.Builder
+	fmt.Fprintf(&decl, "// %s is an option of the %s field\ntype %s string\n\nconst (\n", name, of, name)
+	for _, key := range keys {
+		fmt.Fprintf(&decl, "\t%s %s = %q", g.reserve(name+camelCase(key)), name, key)
+		if label := strings.Join(strings.Fields(labels[key]), " "); label != "" {
+			fmt.Fprintf(&decl, " // %s", label)
+		}
+		decl.WriteString("\n")
+	}
+	decl.WriteString(")\n\n")
+	g.decls = append(g.decls, decl.String())
+	return name
+}
+
+// timeTypeNames are the names of the types of the values of temporal field types
+var timeTypeNames = map[string]string{
+	"date":           "Date",
+	"datetime":       "DateTime",
+	"datetime-local": "LocalDateTime",
+	"time":           "TimeOfDay",
+	"month":          "Month",
+}
+
+// writeTime declares the type of the values of a temporal field type, once, and
+// returns its name. The type embeds a time.Time, is written in the layout
+// ValidateStruct uses for the field type and reads the layouts Bind accepts.
+func (g *generator) writeTime(fieldType string) string {
+	if name, ok := g.timeTypes[fieldType]; ok {
+		return name
+	}
+	if len(g.timeTypes) == 0 {
+		layouts := make([]string, 0, len(zonedLayouts)+len(localLayouts))
+		for _, layout := range append(append([]string{}, zonedLayouts...), localLayouts...) {
+			layouts = append(layouts, strconv.Quote(layout))
+		}
+		g.decls = append(g.decls, fmt.Sprintf(`// timeLayouts are the layouts the date and time types read, most specific first
+var timeLayouts = []string{%s}
+
+// parseTime reads a date, datetime, time or month; empty text is the zero time
+func parseTime(text []byte) (time.Time, error) {
+	if len(text) == 0 {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, string(text)); err == nil {
+			return t, nil
+		}
+	}
+	_, err := time.Parse(timeLayouts[0], string(text))
+	return time.Time{}, err
+}
+
+`, strings.Join(layouts, ", ")))
+	}
+
+	name := g.reserve(timeTypeNames[fieldType])
+	g.timeTypes[fieldType] = name
+	layout := timeLayouts[fieldType]
+	g.decls = append(g.decls, fmt.Sprintf(`// %[1]s is the value of a %[2]s field, written as %[3]s
+type %[1]s struct {
+	time.Time
+}
+
+// MarshalText writes the value as %[3]s, or nothing if it is zero
+func (t %[1]s) MarshalText() ([]byte, error) {
+	if t.IsZero() {
+		return []byte{}, nil
+	}
+	return []byte(t.Format(%[4]q)), nil
+}
+
+// UnmarshalText reads the value in any layout of timeLayouts
+func (t *%[1]s) UnmarshalText(text []byte) error {
+	var err error
+	t.Time, err = parseTime(text)
+	return err
+}
+
+// MarshalJSON writes the value as a JSON string in the layout of MarshalText
+func (t %[1]s) MarshalJSON() ([]byte, error) {
+	text, _ := t.MarshalText()
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON reads the value from a JSON string or null
+func (t *%[1]s) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	return t.UnmarshalText([]byte(text))
+}
+
+`, name, fieldType, layoutExample(layout), layout))
+	return name
+}
+
+// layoutExample describes a time layout for a doc comment
+func layoutExample(layout string) string {
+	if layout == time.RFC3339 {
+		return "RFC 3339"
+	}
+	return layout
+}
+
+// isUnconditionallyRequired reports whether a field is required whatever the data
+func isUnconditionallyRequired(field *Field) bool {
+	required := field.Required
+	if required == nil {
+		required = field.Rules["required"]
+	}
+	switch r := required.(type) {
+	case bool:
+		return r
+	case string:
+		return r == "true"
+	default:
+		return false
+	}
+}
+
+// isIntegerField reports whether a number field only takes whole numbers
+func isIntegerField(field *Field) bool {
+	if digits, ok := field.Rules["digits"]; ok && digits != false {
+		return true
+	}
+	if step, ok := field.Rules["step"]; ok {
+		params := parseRuleParams(step)
+		if len(params) > 0 {
+			n, err := strconv.ParseFloat(params[0], 64)
+			return err == nil && n > 0 && n == float64(int64(n))
+		}
+	}
+	return false
+}
+
+// goIdentifier turns a spec name such as order_id or shipping-address into an
+// exported Go identifier such as OrderID or ShippingAddress
+func goIdentifier(name string) string {
+	id := camelCase(name)
+	if id == "" {
+		return "Field"
+	}
+	if first := []rune(id)[0]; !unicode.IsLetter(first) || !unicode.IsUpper(first) {
+		id = "X" + id
+	}
+	return id
+}
+
+// camelCase joins the letter and digit runs of a name, each starting with a capital
+func camelCase(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var b strings.Builder
+	for _, part := range parts {
+		if upper := strings.ToUpper(part); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
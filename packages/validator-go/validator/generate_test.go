@@ -0,0 +1,186 @@
+package validator
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// generateSpec has a field of every kind the generator maps
+var generateSpec = Spec{Fields: []Field{
+	{Name: "order_id", Type: "text", Label: "Order\nID", Rules: map[string]interface{}{"required": true}},
+	{Name: "qty", Type: "number", Rules: map[string]interface{}{"required": true, "step": 1}},
+	{Name: "price", Type: "number", Rules: map[string]interface{}{"min": 0}},
+	{Name: "gift", Type: "checkbox"},
+	{Name: "delivery", Type: "date", Rules: map[string]interface{}{"required": ".gift == 1", "dateISO": true}},
+	{Name: "opens_at", Type: "time"},
+	{Name: "status", Type: "select", Items: map[string]interface{}{"active": "Active", "paused": "On hold", "1": "One"}},
+	{Name: "colors", Type: "multichoice", Items: map[string]interface{}{"red": "Red", "blue": "Blue"}},
+	{Name: "source", Type: "select", Items: map[string]interface{}{"model": "Source"}},
+	{Name: "tags", Type: "tagify"},
+	{Name: "shipping", Type: "group", Fields: []Field{
+		{Name: "address", Type: "text", Rules: map[string]interface{}{"required": true}},
+		{Name: "zip-code", Type: "text"},
+	}},
+	{Name: "rows", Type: "group", Multiple: true, Fields: []Field{
+		{Name: "sku", Type: "text", Rules: map[string]interface{}{"required": true}},
+		{Name: "status", Type: "choice", Items: map[string]interface{}{"a": "A"}},
+	}},
+	{Name: "Rows", Type: "text"},
+	{Name: "save", Type: "submit"},
+}}
+
+// TestGenerateGo tests the Go types generated from a spec
+func TestGenerateGo(t *testing.T) {
+	source, err := generateSpec.GenerateGo(GenerateOptions{Package: "orders", TypeName: "Order"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(source)
+
+	for _, expected := range []string{
+		"// Code generated by validate gen. DO NOT EDIT.\n\npackage orders\n\nimport (\n\t\"encoding/json\"\n\t\"time\"\n)\n",
+		"// Order holds the fields of the form\ntype Order struct {",
+		"\t// Order ID\n\tOrderID string `json:\"order_id\"`",
+		"Qty int64 `json:\"qty\"`",
+		"Price *float64 `json:\"price,omitempty\"`",
+		"Gift *bool `json:\"gift,omitempty\"`",
+		"Delivery *Date `json:\"delivery,omitempty\"`",
+		"OpensAt *TimeOfDay `json:\"opens_at,omitempty\"`",
+		"// Date is the value of a date field, written as 2006-01-02\ntype Date struct {\n\ttime.Time\n}",
+		"return []byte(t.Format(\"2006-01-02\")), nil",
+		"return []byte(t.Format(\"15:04:05\")), nil",
+		"Status OrderStatus `json:\"status,omitempty\"`",
+		"Colors []OrderColors `json:\"colors,omitempty\"`",
+		"Source string `json:\"source,omitempty\"`",
+		"Tags []string `json:\"tags,omitempty\"`",
+		"Shipping *OrderShipping `json:\"shipping,omitempty\"`",
+		"Rows []OrderRows `json:\"rows,omitempty\"`",
+		"Rows2 string `json:\"Rows,omitempty\"`",
+		"// OrderStatus is an option of the status field\ntype OrderStatus string",
+		"OrderStatus1 OrderStatus = \"1\" // One",
+		"OrderStatusPaused OrderStatus = \"paused\" // On hold",
+		"// OrderRows holds the fields of an item of the rows group\ntype OrderRows struct {",
+		"SKU string `json:\"sku\"`",
+		"Status OrderRowsStatus `json:\"status,omitempty\"`",
+		"ZipCode string `json:\"zip-code,omitempty\"`",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(code), " "), strings.Join(strings.Fields(expected), " ")) {
+			t.Errorf("Expected generated code to contain:\n%s\ngot:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "Save") {
+		t.Error("Expected no field for the submit button")
+	}
+	if strings.Index(code, "type Order struct") > strings.Index(code, "type OrderShipping struct") {
+		t.Error("Expected the form struct before the group structs")
+	}
+
+	empty, err := Spec{}.GenerateGo(GenerateOptions{})
+	if err != nil || !strings.Contains(string(empty), "package forms") || strings.Contains(string(empty), "time") {
+		t.Errorf("Unexpected code for an empty spec: %s, %v", empty, err)
+	}
+
+	for name, expected := range map[string]string{
+		"order_id": "OrderID", "shipping-address": "ShippingAddress", "url": "URL",
+		"2fa": "X2fa", "이름": "X이름", "": "Field", "__": "Field", "userName": "UserName",
+	} {
+		if got := goIdentifier(name); got != expected {
+			t.Errorf("goIdentifier(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+// TestGenerateGoRoundTrip tests that the generated types compile and carry data
+// through Bind, ValidateStruct and encoding/json
+func TestGenerateGoRoundTrip(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+	moduleDir, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := generateSpec.GenerateGo(GenerateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	specJSON, _ := json.Marshal(generateSpec)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module roundtrip\n\ngo 1.21\n\nrequire github.com/example/form-generator/validator v0.0.0\n\n" +
+			"replace github.com/example/form-generator/validator => " + moduleDir + "\n",
+		"forms/form.go": string(source),
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/example/form-generator/validator/validator"
+	"roundtrip/forms"
+)
+
+func main() {
+	var spec validator.Spec
+	json.Unmarshal([]byte(` + "`" + string(specJSON) + "`" + `), &spec)
+	v := validator.NewValidator(spec)
+
+	data := map[string]interface{}{
+		"order_id": "A-1", "qty": "3", "gift": "on", "delivery": "2024-3-9", "opens_at": "9:30", "status": "paused",
+		"colors": "red", "shipping": map[string]interface{}{"address": "Main St"},
+		"rows": []interface{}{map[string]interface{}{"sku": "S1", "status": "a"}},
+	}
+	var form forms.Form
+	bound, err := v.Bind(data, &form)
+	if err != nil || !bound.IsValid {
+		panic(fmt.Sprint(bound.Errors, err))
+	}
+	if form.Status != forms.FormStatusPaused || form.Colors[0] != forms.FormColorsRed || *form.Gift != true {
+		panic(fmt.Sprintf("%+v", form))
+	}
+	validated, err := v.ValidateStruct(form)
+	if err != nil || !validated.IsValid {
+		panic(fmt.Sprint(validated.Errors, err))
+	}
+
+	form.Rows = append(form.Rows, forms.FormRows{})
+	encoded, _ := json.Marshal(form)
+	var decoded map[string]interface{}
+	json.Unmarshal(encoded, &decoded)
+	fmt.Println(decoded["delivery"], decoded["opens_at"])
+	var again forms.Form
+	if err := json.Unmarshal(encoded, &again); err != nil || !again.Delivery.Equal(form.Delivery.Time) {
+		panic(fmt.Sprint(again.Delivery, err))
+	}
+	for _, e := range v.Validate(decoded).Errors {
+		fmt.Println(e.Field, e.Rule)
+	}
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(goTool, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, output)
+	}
+	if got := strings.TrimSpace(string(output)); got != "2024-03-09 09:30:00\nrows.1.sku required" {
+		t.Errorf("Unexpected errors after a JSON round trip:\n%s", got)
+	}
+}
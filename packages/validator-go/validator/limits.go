@@ -0,0 +1,103 @@
+package validator
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Limits bounds the resources a condition expression may use, so that a spec from an
+// untrusted source cannot exhaust the stack or the CPU. A zero field means no limit.
+type Limits struct {
+	MaxLength     int // Bytes of expression source
+	MaxDepth      int // Nesting depth of the AST
+	MaxListSize   int // Values in the list of an in operator
+	MaxExpansions int // Array elements visited through wildcards in one evaluation
+}
+
+// DefaultLimits returns the limits used by Compile and NewConditionParser.
+// They are far above anything a hand-written spec needs.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxLength:     4096,
+		MaxDepth:      100,
+		MaxListSize:   1000,
+		MaxExpansions: 100000,
+	}
+}
+
+// LimitError reports an evaluation that was stopped because it exceeded a limit.
+// Limits checked while parsing are reported as a *ParseError with CodeLimitExceeded.
+type LimitError struct {
+	Limit string // Name of the exceeded Limits field
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("expression evaluation exceeds %s of %d", e.Limit, e.Max)
+}
+
+// checkLength rejects an expression longer than MaxLength before it is tokenized.
+// The error points at the first character past the limit.
+func (l Limits) checkLength(expression string) *ParseError {
+	if l.MaxLength <= 0 || len(expression) <= l.MaxLength {
+		return nil
+	}
+	start := l.MaxLength
+	for start > 0 && !utf8.RuneStart(expression[start]) {
+		start--
+	}
+	return &ParseError{
+		Code:    CodeLimitExceeded,
+		Message: fmt.Sprintf("expression is %d bytes long, exceeding MaxLength of %d", len(expression), l.MaxLength),
+		Start:   start,
+		End:     len(expression),
+	}
+}
+
+// checkDepth rejects an AST nested deeper than MaxDepth. The parser already bounds
+// its recursion; this also catches long chains of left-associative operators.
+func (l Limits) checkDepth(root ASTNode) *ParseError {
+	if l.MaxDepth <= 0 {
+		return nil
+	}
+	node := nodeBelow(root, l.MaxDepth)
+	if node == nil {
+		return nil
+	}
+	pos := node.GetPosition()
+	return &ParseError{
+		Code:    CodeLimitExceeded,
+		Message: fmt.Sprintf("expression is nested deeper than MaxDepth of %d", l.MaxDepth),
+		Start:   pos.Start,
+		End:     pos.End,
+	}
+}
+
+// nodeBelow returns the first node more than depth levels below node (node itself
+// being level 1), or nil. It never descends further than that.
+func nodeBelow(node ASTNode, depth int) ASTNode {
+	if depth == 0 {
+		return node
+	}
+	for _, child := range Children(node) {
+		if deep := nodeBelow(child, depth-1); deep != nil {
+			return deep
+		}
+	}
+	return nil
+}
+
+// spend counts n array elements visited through a wildcard.
+// Once MaxExpansions is exceeded it records a LimitError and returns false; the
+// evaluator then stops and every further node evaluates to nil.
+func (e *evaluator) spend(n int) bool {
+	if e.err != nil {
+		return false
+	}
+	e.expansions += n
+	if max := e.limits.MaxExpansions; max > 0 && e.expansions > max {
+		e.err = &LimitError{Limit: "MaxExpansions", Max: max}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseErrorCode classifies a syntax error in a condition expression
+type ParseErrorCode string
+
+// Parse error codes
+const (
+	CodeUnterminatedString  ParseErrorCode = "unterminated_string"
+	CodeInvalidCharacter    ParseErrorCode = "invalid_character"
+	CodeUnexpectedToken     ParseErrorCode = "unexpected_token"
+	CodeExpectedExpression  ParseErrorCode = "expected_expression"
+	CodeExpectedValue       ParseErrorCode = "expected_value"
+	CodeExpectedPathSegment ParseErrorCode = "expected_path_segment"
+	CodeMissingDelimiter    ParseErrorCode = "missing_delimiter" // unclosed ( or [, or ? without :
+	CodeLimitExceeded       ParseErrorCode = "limit_exceeded"    // expression exceeds one of the parser Limits
+	CodeInvalidRegex        ParseErrorCode = "invalid_regex"     // =~ pattern that is not a valid RE2 expression
+)
+
+// ParseError is a syntax error in a condition expression.
+// Start and End are byte offsets of the offending token in the expression;
+// Line and Column are 1-based and count characters, not bytes.
+type ParseError struct {
+	Code    ParseErrorCode `json:"code"`
+	Message string         `json:"message"`
+	Start   int            `json:"start"`
+	End     int            `json:"end"`
+	Line    int            `json:"line"`
+	Column  int            `json:"column"`
+}
+
+func (e *ParseError) Error() string {
+	if e.Line > 1 {
+		return fmt.Sprintf("%s at line %d, column %d", e.Message, e.Line, e.Column)
+	}
+	return fmt.Sprintf("%s at column %d", e.Message, e.Column)
+}
+
+// Caret renders the line of the expression containing the error with a caret below it
+func (e *ParseError) Caret(expression string) string {
+	return RenderCaret(expression, e.Start, e.End)
+}
+
+// locate sets Line and Column from the byte offset in the expression
+func (e *ParseError) locate(expression string) {
+	e.Line, e.Column = lineColumn(expression, e.Start)
+}
+
+// ParseErrors is the list of syntax errors of an expression with more than one
+// malformed operand, in source order
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap allows errors.As to find the first *ParseError
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// ParseErrorsOf returns the syntax errors carried by an error returned from Compile or Parse,
+// or nil if it is not a syntax error
+func ParseErrorsOf(err error) []*ParseError {
+	switch e := err.(type) {
+	case *ParseError:
+		return []*ParseError{e}
+	case ParseErrors:
+		return e
+	default:
+		return nil
+	}
+}
+
+// RenderCaret renders the line of an expression containing the byte range
+// [start, end) with carets under the range:
+//
+//	.is_sale == && .price > 0
+//	            ^^
+func RenderCaret(expression string, start, end int) string {
+	if start > len(expression) {
+		start = len(expression)
+	}
+	if end < start {
+		end = start
+	}
+
+	lineStart := strings.LastIndexByte(expression[:start], '\n') + 1
+	lineEnd := len(expression)
+	if i := strings.IndexByte(expression[start:], '\n'); i >= 0 {
+		lineEnd = start + i
+	}
+	if end > lineEnd {
+		end = lineEnd
+	}
+
+	// Keep tabs so that the caret lines up under the source text
+	var indent strings.Builder
+	for _, r := range expression[lineStart:start] {
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteByte(' ')
+		}
+	}
+
+	width := utf8.RuneCountInString(expression[start:end])
+	if width == 0 {
+		width = 1
+	}
+	return expression[lineStart:lineEnd] + "\n" + indent.String() + strings.Repeat("^", width)
+}
+
+// lineColumn converts a byte offset in an expression to a 1-based line and character column
+func lineColumn(expression string, offset int) (int, int) {
+	if offset > len(expression) {
+		offset = len(expression)
+	}
+	before := expression[:offset]
+	lineStart := strings.LastIndexByte(before, '\n') + 1
+	return strings.Count(before, "\n") + 1, utf8.RuneCountInString(before[lineStart:]) + 1
+}
+
+// offsetAt converts a 1-based line and character column to a byte offset in an expression
+func offsetAt(expression string, line, column int) int {
+	offset := 0
+	for l := 1; l < line; l++ {
+		i := strings.IndexByte(expression[offset:], '\n')
+		if i < 0 {
+			return len(expression)
+		}
+		offset += i + 1
+	}
+	for c := 1; c < column && offset < len(expression); c++ {
+		_, size := utf8.DecodeRuneInString(expression[offset:])
+		offset += size
+	}
+	return offset
+}
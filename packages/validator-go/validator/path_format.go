@@ -0,0 +1,167 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PathFormat is a notation for the field paths of validation results
+type PathFormat int
+
+const (
+	// DotPath joins segments with dots, as in items.0.name (the default)
+	DotPath PathFormat = iota
+	// BracketPath writes paths like form input names, as in items[0][name]
+	BracketPath
+	// JSONPointerPath writes RFC 6901 JSON Pointers, as in /items/0/name
+	JSONPointerPath
+)
+
+// pathFormatNames are the names of the path formats in flags and configuration
+var pathFormatNames = map[PathFormat]string{
+	DotPath:         "dot",
+	BracketPath:     "bracket",
+	JSONPointerPath: "pointer",
+}
+
+// jsonPointerEscaper escapes ~ and / in the segments of a JSON Pointer
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Format writes a path in the format. An empty path is the empty string, which as a
+// JSON Pointer refers to the whole data. Segments of a dot path should not contain
+// dots, and those of a bracket path should not contain brackets; JSON Pointers
+// escape ~ and / and can hold any segment.
+func (f PathFormat) Format(path []string) string {
+	switch f {
+	case BracketPath:
+		if len(path) == 0 {
+			return ""
+		}
+		var b strings.Builder
+		b.WriteString(path[0])
+		for _, segment := range path[1:] {
+			b.WriteString("[" + segment + "]")
+		}
+		return b.String()
+	case JSONPointerPath:
+		var b strings.Builder
+		for _, segment := range path {
+			b.WriteString("/" + jsonPointerEscaper.Replace(segment))
+		}
+		return b.String()
+	default:
+		return strings.Join(path, ".")
+	}
+}
+
+// Parse reads a path written in the format. It returns an error for a JSON Pointer
+// that does not start with / or has an invalid ~ escape, and for a bracket path
+// whose brackets are not well formed.
+func (f PathFormat) Parse(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	switch f {
+	case BracketPath:
+		path, ok := parseBracketPath(s)
+		if !ok {
+			return nil, fmt.Errorf("cannot parse %q as a bracket path", s)
+		}
+		return path, nil
+	case JSONPointerPath:
+		if s[0] != '/' {
+			return nil, fmt.Errorf("cannot parse %q as a JSON Pointer: it must start with /", s)
+		}
+		path := strings.Split(s[1:], "/")
+		for i, segment := range path {
+			unescaped, ok := unescapeJSONPointer(segment)
+			if !ok {
+				return nil, fmt.Errorf("cannot parse %q as a JSON Pointer: invalid escape in %q", s, segment)
+			}
+			path[i] = unescaped
+		}
+		return path, nil
+	default:
+		return strings.Split(s, "."), nil
+	}
+}
+
+// String returns the name of the format: dot, bracket or pointer
+func (f PathFormat) String() string {
+	if name, ok := pathFormatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("PathFormat(%d)", int(f))
+}
+
+// MarshalText writes the name of the format
+func (f PathFormat) MarshalText() ([]byte, error) {
+	if _, ok := pathFormatNames[f]; !ok {
+		return nil, fmt.Errorf("cannot marshal unknown path format %d", int(f))
+	}
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText reads the name of a format, so that it can be set from a flag
+func (f *PathFormat) UnmarshalText(text []byte) error {
+	for format, name := range pathFormatNames {
+		if string(text) == name {
+			*f = format
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown path format %q, want dot, bracket or pointer", text)
+}
+
+// unescapeJSONPointer decodes ~0 and ~1 in a JSON Pointer segment, reporting false
+// for a ~ followed by anything else
+func unescapeJSONPointer(segment string) (string, bool) {
+	if !strings.Contains(segment, "~") {
+		return segment, true
+	}
+	var b strings.Builder
+	for i := 0; i < len(segment); i++ {
+		if segment[i] != '~' {
+			b.WriteByte(segment[i])
+			continue
+		}
+		if i+1 == len(segment) {
+			return "", false
+		}
+		switch segment[i+1] {
+		case '0':
+			b.WriteByte('~')
+		case '1':
+			b.WriteByte('/')
+		default:
+			return "", false
+		}
+		i++
+	}
+	return b.String(), true
+}
+
+// parseBracketPath splits a path in bracket notation, such as items[0][name], into
+// segments, with an empty segment for []. A path without brackets is a single
+// segment; ok is false if the brackets are not well formed.
+func parseBracketPath(s string) (path []string, ok bool) {
+	open := strings.IndexByte(s, '[')
+	if open < 0 {
+		return []string{s}, true
+	}
+	if open == 0 {
+		return nil, false
+	}
+
+	path = []string{s[:open]}
+	rest := s[open:]
+	for rest != "" {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return nil, false
+		}
+		path = append(path, rest[1:end])
+		rest = rest[end+1:]
+	}
+	return path, true
+}
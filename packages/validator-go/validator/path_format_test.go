@@ -0,0 +1,136 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestPathFormat tests writing and reading paths in each format
+func TestPathFormat(t *testing.T) {
+	path := []string{"items", "0", "unit.price", "a/b~c"}
+	tests := []struct {
+		format PathFormat
+		want   string
+	}{
+		{DotPath, "items.0.unit.price.a/b~c"},
+		{BracketPath, "items[0][unit.price][a/b~c]"},
+		{JSONPointerPath, "/items/0/unit.price/a~1b~0c"},
+	}
+	for _, tt := range tests {
+		got := tt.format.Format(path)
+		if got != tt.want {
+			t.Errorf("%v: expected %s, got %s", tt.format, tt.want, got)
+		}
+		if tt.format == DotPath {
+			continue
+		}
+		parsed, err := tt.format.Parse(got)
+		if err != nil || !reflect.DeepEqual(parsed, path) {
+			t.Errorf("%v: expected %q to parse back, got %q, %v", tt.format, got, parsed, err)
+		}
+		if parsed := StringToPath(got); !reflect.DeepEqual(parsed, path) {
+			t.Errorf("StringToPath(%q) = %q", got, parsed)
+		}
+	}
+
+	if got := JSONPointerPath.Format(nil); got != "" {
+		t.Errorf("Expected the empty pointer for the root, got %q", got)
+	}
+	if got, _ := JSONPointerPath.Parse("/"); !reflect.DeepEqual(got, []string{""}) {
+		t.Errorf("Expected / to hold an empty segment, got %q", got)
+	}
+	for _, invalid := range []struct {
+		format PathFormat
+		s      string
+	}{
+		{JSONPointerPath, "items/0"},
+		{JSONPointerPath, "/items~2"},
+		{JSONPointerPath, "/items~"},
+		{BracketPath, "[0][name]"},
+		{BracketPath, "items[0"},
+		{BracketPath, "items[0]name"},
+	} {
+		if _, err := invalid.format.Parse(invalid.s); err == nil {
+			t.Errorf("%v: expected an error for %q", invalid.format, invalid.s)
+		}
+	}
+
+	// Strings that are not well-formed pointers or bracket paths are dot paths
+	for s, want := range map[string][]string{
+		"items.0.name": {"items", "0", "name"},
+		"items[0":      {"items[0"},
+		"a.b[c]":       {"a.b", "c"},
+		"/items~x":     {"/items~x"},
+	} {
+		if got := StringToPath(s); !reflect.DeepEqual(got, want) {
+			t.Errorf("StringToPath(%q) = %q, want %q", s, got, want)
+		}
+	}
+
+	var format PathFormat
+	if err := format.UnmarshalText([]byte("pointer")); err != nil || format != JSONPointerPath {
+		t.Errorf("Expected pointer to read as JSONPointerPath, got %v, %v", format, err)
+	}
+	if err := format.UnmarshalText([]byte("slash")); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+// TestPathFormatResults tests the field paths of validation results and lookups
+// with field names containing dots
+func TestPathFormatResults(t *testing.T) {
+	spec := Spec{Fields: []Field{
+		{Name: "items", Type: "group", Multiple: true, Fields: []Field{
+			{Name: "unit.price", Type: "number", Rules: map[string]interface{}{"required": true, "min": 1}},
+		}},
+	}}
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"unit.price": 0},
+			map[string]interface{}{},
+		},
+	}
+
+	for format, want := range map[PathFormat]string{
+		DotPath:         "items.0.unit.price items.1.unit.price",
+		BracketPath:     "items[0][unit.price] items[1][unit.price]",
+		JSONPointerPath: "/items/0/unit.price /items/1/unit.price",
+	} {
+		v := NewValidator(spec, WithPathFormat(format))
+		var got []string
+		for _, e := range v.Validate(data).Errors {
+			got = append(got, e.Field)
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%v: expected %s, got %s", format, want, strings.Join(got, " "))
+		}
+
+		var target struct {
+			Items []struct {
+				Price float64 `json:"unit.price"`
+			} `json:"items"`
+		}
+		result, _ := v.Bind(map[string]interface{}{"items": []interface{}{map[string]interface{}{"unit.price": "x"}}}, &target)
+		if len(result.Errors) != 1 || result.Errors[0].Field != strings.Fields(want)[0] {
+			t.Errorf("%v: expected a bind error at %s, got %v", format, strings.Fields(want)[0], result.Errors)
+		}
+	}
+
+	v := NewValidator(spec)
+	for _, path := range []string{"items[0][unit.price]", "/items/0/unit.price"} {
+		if msg := v.ValidateField(path, 0, data); msg == nil {
+			t.Errorf("Expected ValidateField(%q) to apply the min rule", path)
+		}
+	}
+
+	pr := NewPathResolver(data)
+	for _, path := range []string{"items[0][unit.price]", "/items/0/unit.price"} {
+		if got := pr.GetValueByPathString(path, nil); got != 0 {
+			t.Errorf("Expected %q to resolve to 0, got %v", path, got)
+		}
+	}
+	if got := pr.ResolvePath("..items[1][unit.price]", []string{"note"}); !reflect.DeepEqual(got, []string{"items", "1", "unit.price"}) {
+		t.Errorf("Unexpected relative bracket path %q", got)
+	}
+}
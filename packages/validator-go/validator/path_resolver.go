@@ -1,10 +1,74 @@
 package validator
 
 import (
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// uniqueKeyPattern matches the unique key of an item of a repeatable field, two
+// underscores around 13 lowercase letters and digits (see docs/FIELD-KEYS.md)
+var uniqueKeyPattern = regexp.MustCompile(`^__[a-z0-9]{13}__$`)
+
+// IsUniqueKey reports whether a path segment is the unique key of an item
+func IsUniqueKey(segment string) bool {
+	return uniqueKeyPattern.MatchString(segment)
+}
+
+// IsItemSegment reports whether a path segment selects an item of a repeatable
+// field: a list index or a unique key
+func IsItemSegment(segment string) bool {
+	if _, err := strconv.Atoi(segment); err == nil {
+		return true
+	}
+	return IsUniqueKey(segment)
+}
+
+// repeatableItems returns the items of the value of a repeatable field with the path
+// segment of each: the elements of a list at their indices, or the items of a keyed
+// collection, a non-empty map whose keys are all unique keys, in sorted key order.
+// ok is false for other values.
+func repeatableItems(value interface{}) (items []interface{}, segments []string, ok bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		segments = make([]string, len(v))
+		for i := range v {
+			segments[i] = strconv.Itoa(i)
+		}
+		return v, segments, true
+	case map[string]interface{}:
+		segments, ok = uniqueKeys(v)
+		if !ok {
+			return nil, nil, false
+		}
+		items = make([]interface{}, len(segments))
+		for i, key := range segments {
+			items[i] = v[key]
+		}
+		return items, segments, true
+	default:
+		return nil, nil, false
+	}
+}
+
+// uniqueKeys returns the keys of a keyed collection in sorted order, or false if the
+// map is empty or has a key that is not a unique key
+func uniqueKeys(m map[string]interface{}) ([]string, bool) {
+	if len(m) == 0 {
+		return nil, false
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		if !IsUniqueKey(key) {
+			return nil, false
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, true
+}
+
 // PathResolver resolves field paths in form data
 type PathResolver struct {
 	formData map[string]interface{}
@@ -17,7 +81,9 @@ func NewPathResolver(formData map[string]interface{}) *PathResolver {
 	}
 }
 
-// ResolvePath resolves a path string to an absolute path array
+// ResolvePath resolves a path string to an absolute path array. Absolute paths may
+// be written with dots, in bracket notation or as JSON Pointers (see StringToPath);
+// relative paths start with dots, followed by a path with dots or in bracket notation.
 func (pr *PathResolver) ResolvePath(pathStr string, currentPath []string) []string {
 	if pathStr == "" {
 		return currentPath
@@ -76,6 +142,11 @@ func (pr *PathResolver) parsePathString(pathStr string) []string {
 		pathStr = pathStr[1:]
 	}
 
+	// JSON Pointers and bracket notation keep their segments as written
+	if path, ok := parseMarkedPath(pathStr); ok {
+		return path
+	}
+
 	// Split by dots
 	parts := strings.Split(pathStr, ".")
 	var result []string
@@ -178,14 +249,34 @@ func PathToString(path []string) string {
 	return strings.Join(path, ".")
 }
 
-// StringToPath converts a dot-separated string to a path array
+// StringToPath converts a path string to a path array. A string starting with / is
+// read as a JSON Pointer (/items/0/name), a string with well-formed brackets in
+// bracket notation (items[0][name]), and any other string as dot-separated. Field
+// names containing dots can be written in the first two formats.
 func StringToPath(pathStr string) []string {
 	if pathStr == "" {
 		return nil
 	}
+	if path, ok := parseMarkedPath(pathStr); ok {
+		return path
+	}
 	return strings.Split(pathStr, ".")
 }
 
+// parseMarkedPath parses a path that is a JSON Pointer or in bracket notation, telling
+// them apart from dot-separated paths by a leading / or by brackets
+func parseMarkedPath(pathStr string) ([]string, bool) {
+	switch {
+	case strings.HasPrefix(pathStr, "/"):
+		path, err := JSONPointerPath.Parse(pathStr)
+		return path, err == nil
+	case strings.Contains(pathStr, "["):
+		return parseBracketPath(pathStr)
+	default:
+		return nil, false
+	}
+}
+
 // PathContainsWildcard checks if a path contains a wildcard (*)
 func PathContainsWildcard(path []string) bool {
 	for _, segment := range path {
@@ -196,7 +287,8 @@ func PathContainsWildcard(path []string) bool {
 	return false
 }
 
-// ExpandWildcardPath expands a path with wildcard to all matching paths
+// ExpandWildcardPath expands a path with wildcard to all matching paths. A wildcard
+// over a keyed collection expands to its unique keys, in sorted order.
 func (pr *PathResolver) ExpandWildcardPath(path []string) [][]string {
 	wildcardIndex := -1
 	for i, segment := range path {
@@ -210,23 +302,23 @@ func (pr *PathResolver) ExpandWildcardPath(path []string) [][]string {
 		return [][]string{path}
 	}
 
-	// Get the array at the path before the wildcard
+	// Get the items at the path before the wildcard
 	arrayPath := path[:wildcardIndex]
 	arrayData := pr.GetValue(arrayPath)
 
-	arr, ok := arrayData.([]interface{})
+	_, segments, ok := repeatableItems(arrayData)
 	if !ok {
 		return nil
 	}
 
-	// Generate paths for each array element
+	// Generate paths for each item, at its index or unique key
 	remainingPath := path[wildcardIndex+1:]
 	var result [][]string
 
-	for i := range arr {
+	for _, segment := range segments {
 		expandedPath := make([]string, len(arrayPath)+1+len(remainingPath))
 		copy(expandedPath, arrayPath)
-		expandedPath[len(arrayPath)] = strconv.Itoa(i)
+		expandedPath[len(arrayPath)] = segment
 		copy(expandedPath[len(arrayPath)+1:], remainingPath)
 
 		// Recursively expand if there are more wildcards
@@ -0,0 +1,205 @@
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// plan is a spec compiled for validation. NewValidator builds it once so that
+// Validate does not look up rules by name, parse rule parameters, or compile
+// conditions and patterns again for every call. A plan is never modified after it
+// is built; changing the rules or limits of a validator builds a new one.
+type plan struct {
+	fields    []*fieldPlan
+	streaming *streamPlan               // Groups ValidateStream can validate item by item
+	patterns  map[string]*regexp.Regexp // Compiled match patterns of the spec
+}
+
+// fieldPlan is the compiled form of a field
+type fieldPlan struct {
+	field    *Field       // Copy of the spec field
+	path     []string     // Data path of the field, without the indices of repeatable groups
+	children []*fieldPlan // Fields of a group
+
+	required     bool        // Unconditionally required
+	requiredExpr *Expression // Condition of a conditional required; nil if none or invalid
+	requiredErr  error       // Parse error of a conditional required, reported when the field is empty
+	number       RuleFunc    // Implicit number rule of a number field without an explicit one
+	rules        []*rulePlan // Rules other than required, ordered by name
+}
+
+// rulePlan is a rule of a field with its function and parameters resolved
+type rulePlan struct {
+	name   string
+	value  interface{} // Rule value as written in the spec
+	fn     RuleFunc
+	custom *Rule       // Spec rule, used when no rule function has the name
+	params []string    // Parameters of a constant rule value
+	expr   *Expression // Ternary expression the parameters are resolved from
+}
+
+// compilePlan compiles the spec with the current rules and condition parser
+func (c *config) compilePlan() *plan {
+	p := &plan{patterns: map[string]*regexp.Regexp{}}
+	p.fields = c.compileFields(p, c.spec.Fields, nil)
+	p.streaming = c.compileStreaming(p.fields)
+	return p
+}
+
+// compileFields compiles a list of fields and their children
+func (c *config) compileFields(p *plan, fields []Field, parent []string) []*fieldPlan {
+	plans := make([]*fieldPlan, len(fields))
+	for i := range fields {
+		field := fields[i]
+		path := AppendToPath(parent, field.Name)
+		fp := &fieldPlan{field: &field, path: path, children: c.compileFields(p, field.Fields, path)}
+		fp.required, fp.requiredExpr, fp.requiredErr = c.compileRequired(&field)
+
+		if field.Type == "number" {
+			if _, explicit := field.Rules["number"]; !explicit {
+				fp.number = c.rules["number"]
+			}
+		}
+
+		names := make([]string, 0, len(field.Rules))
+		for name := range field.Rules {
+			if name != "required" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if rp := c.compileRule(p, name, field.Rules[name]); rp != nil {
+				fp.rules = append(fp.rules, rp)
+			}
+		}
+
+		plans[i] = fp
+	}
+	return plans
+}
+
+// compileRequired resolves the required setting of a field, from Required or else the
+// required rule: a constant, or a condition compiled once. A condition that does not
+// parse is returned as an error, so that an empty value fails rather than passes.
+func (c *config) compileRequired(field *Field) (bool, *Expression, error) {
+	reqValue := field.Required
+	if reqValue == nil {
+		reqValue = field.Rules["required"]
+	}
+
+	switch req := reqValue.(type) {
+	case bool:
+		return req, nil, nil
+	case string:
+		if req == "true" {
+			return true, nil, nil
+		}
+		if !isConditionExpression(req) {
+			return false, nil, nil
+		}
+		expr, err := c.conditionParser.Compile(req)
+		if err != nil {
+			return false, nil, fmt.Errorf("cannot parse the required condition: %w", err)
+		}
+		return false, expr, nil
+	default:
+		return false, nil, nil
+	}
+}
+
+// compileRule resolves a rule by name. Rule functions take precedence over rules
+// defined in the spec; a rule known to neither is dropped.
+func (c *config) compileRule(p *plan, name string, value interface{}) *rulePlan {
+	rp := &rulePlan{name: name, value: value}
+
+	fn, ok := c.rules[name]
+	if !ok {
+		custom, ok := c.spec.Rules[name]
+		if !ok {
+			return nil
+		}
+		rp.custom = &custom
+		if custom.Pattern != "" {
+			p.compilePattern(custom.Pattern)
+		}
+		return rp
+	}
+	rp.fn = fn
+
+	// Conditional rule values (ternary expressions) are resolved on every call
+	if strVal, ok := value.(string); ok && isTernaryCandidate(strVal) {
+		if expr, err := c.conditionParser.Compile(strVal); err == nil {
+			rp.expr = expr
+			return rp
+		}
+	}
+
+	rp.params = parseRuleParams(value)
+	if name == "match" && len(rp.params) > 0 {
+		p.compilePattern(rp.params[0])
+	}
+	return rp
+}
+
+// compilePattern compiles a match pattern of the spec for the match rule; patterns
+// that do not compile are reported by the rule
+func (p *plan) compilePattern(pattern string) {
+	if re, err := regexp.Compile(pattern); err == nil {
+		p.patterns[pattern] = re
+	}
+}
+
+// pathIn returns the data path of the field in a group at currentPath. Outside
+// repeatable groups, where currentPath has no item indices, this is the compiled path.
+func (fp *fieldPlan) pathIn(currentPath []string) []string {
+	if len(currentPath)+1 == len(fp.path) {
+		return fp.path
+	}
+	return AppendToPath(currentPath, fp.field.Name)
+}
+
+// isRequired reports whether the field is required for the given data, or the error
+// of a condition that does not parse or whose evaluation exceeded a limit
+func (fp *fieldPlan) isRequired(allData map[string]interface{}, currentPath []string, env Env) (bool, error) {
+	if fp.requiredErr != nil {
+		return false, fp.requiredErr
+	}
+	if fp.requiredExpr == nil {
+		return fp.required, nil
+	}
+	value, err := fp.requiredExpr.EvalEnv(allData, currentPath, env)
+	if err != nil {
+		return false, err
+	}
+	return isTruthy(value), nil
+}
+
+// find returns the plan of the field at a data path, skipping the item indices and
+// unique keys of repeatable groups, or nil if no field matches
+func (p *plan) find(path []string) *fieldPlan {
+	fields := p.fields
+	var found *fieldPlan
+	for i, segment := range path {
+		if IsItemSegment(segment) {
+			if i == len(path)-1 {
+				return nil
+			}
+			continue
+		}
+
+		found = nil
+		for _, fp := range fields {
+			if fp.field.Name == segment {
+				found = fp
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		fields = found.children
+	}
+	return found
+}
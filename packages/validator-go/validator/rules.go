@@ -2,6 +2,7 @@ package validator
 
 import (
 	"math"
+	"mime/multipart"
 	"net/url"
 	"reflect"
 	"regexp"
@@ -11,6 +12,17 @@ import (
 	"unicode/utf8"
 )
 
+// Patterns of the built-in rules, compiled once
+var (
+	// RFC 5322 compliant email regex (simplified)
+	emailPattern  = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+	digitsPattern = regexp.MustCompile(`^\d+$`)
+	// YYYY-MM-DD
+	dateISOPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	// Optional sign, followed by digits with optional decimal point
+	numberPattern = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\d*\.?\d+)$`)
+)
+
 // DefaultRules returns the built-in validation rules
 func DefaultRules() map[string]RuleFunc {
 	return map[string]RuleFunc{
@@ -56,10 +68,7 @@ func ruleEmail(value interface{}, params []string, allData map[string]interface{
 	}
 
 	str := toString(value)
-	// RFC 5322 compliant email regex (simplified)
-	pattern := `^[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`
-	matched, _ := regexp.MatchString(pattern, str)
-	if !matched {
+	if !emailPattern.MatchString(str) {
 		msg := "Please enter a valid email address"
 		return &msg
 	}
@@ -197,6 +206,17 @@ func ruleMax(value interface{}, params []string, allData map[string]interface{},
 	return nil
 }
 
+// pattern returns a compiled match pattern. Patterns of the spec are compiled with the
+// validator; others, such as those of ternary rule values, are compiled on every call.
+func (ctx *ValidationContext) pattern(pattern string) (*regexp.Regexp, error) {
+	if ctx != nil {
+		if re, ok := ctx.patterns[pattern]; ok {
+			return re, nil
+		}
+	}
+	return regexp.Compile(pattern)
+}
+
 // ruleMatch validates against a regex pattern
 func ruleMatch(value interface{}, params []string, allData map[string]interface{}, ctx *ValidationContext) *string {
 	if isEmpty(value) {
@@ -207,16 +227,13 @@ func ruleMatch(value interface{}, params []string, allData map[string]interface{
 		return nil
 	}
 
-	pattern := params[0]
-	str := toString(value)
-
-	matched, err := regexp.MatchString(pattern, str)
+	re, err := ctx.pattern(params[0])
 	if err != nil {
 		msg := "Invalid pattern"
 		return &msg
 	}
 
-	if !matched {
+	if !re.MatchString(toString(value)) {
 		msg := "Please enter a value matching the required format"
 		return &msg
 	}
@@ -284,17 +301,56 @@ func isEmpty(value interface{}) bool {
 		return false
 	case bool:
 		return false
+	case time.Time:
+		return v.IsZero()
 	default:
+		if basic, ok := basicValue(value); ok {
+			return isEmpty(basic)
+		}
 		// Use reflection to handle any slice type (e.g., []string, []int)
 		// This matches JS behavior where empty arrays fail required validation
 		val := reflect.ValueOf(value)
-		if val.Kind() == reflect.Slice {
+		if val.Kind() == reflect.Slice || val.Kind() == reflect.Map {
 			return val.Len() == 0
 		}
 		return false
 	}
 }
 
+// basicValue returns the value a pointer points to (nil for a nil pointer), or the
+// value of a named type, such as json.Number, as its underlying basic type: string,
+// bool, int64, uint64, float32 or float64. It returns false for other values and
+// for values that already have one of the basic types.
+func basicValue(value interface{}) (interface{}, bool) {
+	switch value.(type) {
+	case nil, string, bool, int, int32, int64, uint64, float32, float64:
+		return nil, false
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil, true
+		}
+		return rv.Elem().Interface(), true
+	case reflect.String:
+		return rv.String(), true
+	case reflect.Bool:
+		return rv.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), true
+	case reflect.Float32:
+		return float32(rv.Float()), true
+	case reflect.Float64:
+		return rv.Float(), true
+	default:
+		return nil, false
+	}
+}
+
 // toString converts a value to string
 func toString(value interface{}) string {
 	if value == nil {
@@ -308,11 +364,22 @@ func toString(value interface{}) string {
 		return strconv.Itoa(v)
 	case int64:
 		return strconv.FormatInt(v, 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
 	case float64:
 		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
 	case bool:
 		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
 	default:
+		if basic, ok := basicValue(value); ok {
+			return toString(basic)
+		}
 		return ""
 	}
 }
@@ -330,6 +397,8 @@ func toFloat64(value interface{}) (float64, bool) {
 		return float64(v), true
 	case int32:
 		return float64(v), true
+	case uint64:
+		return float64(v), true
 	case string:
 		f, err := strconv.ParseFloat(v, 64)
 		if err != nil {
@@ -337,6 +406,9 @@ func toFloat64(value interface{}) (float64, bool) {
 		}
 		return f, true
 	default:
+		if basic, ok := basicValue(value); ok {
+			return toFloat64(basic)
+		}
 		return 0, false
 	}
 }
@@ -373,9 +445,7 @@ func toNumber(value interface{}) (float64, bool) {
 			return f, err == nil
 		}
 		// Validate string is a proper number format (not partial like "12abc")
-		// Pattern: optional sign, followed by digits with optional decimal point
-		matched, _ := regexp.MatchString(`^[-+]?(\d+\.?\d*|\d*\.?\d+)$`, trimmed)
-		if !matched {
+		if !numberPattern.MatchString(trimmed) {
 			return 0, false
 		}
 		f, err := strconv.ParseFloat(trimmed, 64)
@@ -383,7 +453,12 @@ func toNumber(value interface{}) (float64, bool) {
 			return 0, false
 		}
 		return f, true
+	case uint64:
+		return float64(v), true
 	default:
+		if basic, ok := basicValue(value); ok {
+			return toNumber(basic)
+		}
 		return 0, false
 	}
 }
@@ -463,9 +538,7 @@ func ruleDigits(value interface{}, params []string, allData map[string]interface
 		return nil
 	}
 
-	str := toString(value)
-	matched, _ := regexp.MatchString(`^\d+$`, str)
-	if !matched {
+	if !digitsPattern.MatchString(toString(value)) {
 		msg := "Please enter only digits"
 		return &msg
 	}
@@ -558,8 +631,7 @@ func ruleDateISO(value interface{}, params []string, allData map[string]interfac
 	str := toString(value)
 
 	// Check format YYYY-MM-DD
-	matched, _ := regexp.MatchString(`^\d{4}-\d{2}-\d{2}$`, str)
-	if !matched {
+	if !dateISOPattern.MatchString(str) {
 		msg := "Please enter a valid date in ISO format (YYYY-MM-DD)"
 		return &msg
 	}
@@ -665,7 +737,29 @@ func ruleAccept(value interface{}, params []string, allData map[string]interface
 		return nil
 	}
 
-	acceptList := params
+	// Like the HTML accept attribute, a parameter may list several types
+	var acceptList []string
+	for _, param := range params {
+		acceptList = append(acceptList, strings.Split(param, ",")...)
+	}
+
+	switch v := value.(type) {
+	case *multipart.FileHeader:
+		// An uploaded file matches by its name or the content type the browser sent
+		if !matchesExtension(v.Filename, acceptList) && !matchesMimeType(v.Header.Get("Content-Type"), acceptList) {
+			msg := "Please upload a file with a valid format"
+			return &msg
+		}
+		return nil
+	case []interface{}:
+		// Every file of a multiple file field must match
+		for _, item := range v {
+			if msg := ruleAccept(item, params, allData, ctx); msg != nil {
+				return msg
+			}
+		}
+		return nil
+	}
 
 	// Handle string value (filename or MIME type)
 	str := toString(value)
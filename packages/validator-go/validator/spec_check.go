@@ -0,0 +1,282 @@
+package validator
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// Check parses every condition expression in the spec (required, display_switch,
+// ternary rule values and computed) and reports the ones that cannot be compiled,
+// as well as path references that do not resolve to a field of the spec.
+// Run it when loading a spec so that a broken expression fails at deploy time
+// instead of silently disabling a rule at request time.
+func (s Spec) Check() []Diagnostic {
+	return s.CheckWithOptions(CheckOptions{})
+}
+
+// CheckOptions enables optional analysis passes of Spec.CheckWithOptions
+type CheckOptions struct {
+	// Types enables type inference over expressions, reporting comparisons that
+	// can never match as warnings
+	Types bool
+
+	// Variables names the $ variables the environment provides (without the $).
+	// References to any other variable that is not built in are reported as errors.
+	Variables []string
+
+	// Limits overrides the limits expressions are compiled under; nil means
+	// DefaultLimits. Use the limits the validator is configured with.
+	Limits *Limits
+}
+
+// CheckWithOptions is like Check but runs the optional passes enabled in opts
+func (s Spec) CheckWithOptions(opts CheckOptions) []Diagnostic {
+	checker := &specChecker{fields: s.Fields, options: opts}
+	checker.checkFields(s.Fields, nil)
+	return checker.diagnostics
+}
+
+// Caret renders the line of the expression with carets under the problem,
+// or an empty string if the diagnostic has no location
+func (d Diagnostic) Caret() string {
+	if d.Line == 0 {
+		return ""
+	}
+	start := offsetAt(d.Expression, d.Line, d.Column)
+	end := start
+	for i := 0; i < d.Length && end < len(d.Expression); i++ {
+		_, size := utf8.DecodeRuneInString(d.Expression[end:])
+		end += size
+	}
+	return RenderCaret(d.Expression, start, end)
+}
+
+// String formats a diagnostic as "severity: field (source): message"
+func (d Diagnostic) String() string {
+	if d.Line > 1 {
+		return fmt.Sprintf("%s: %s (%s): %s at line %d, column %d in %q", d.Severity, d.Field, d.Source, d.Message, d.Line, d.Column, d.Expression)
+	}
+	if d.Column > 0 {
+		return fmt.Sprintf("%s: %s (%s): %s at column %d in %q", d.Severity, d.Field, d.Source, d.Message, d.Column, d.Expression)
+	}
+	return fmt.Sprintf("%s: %s (%s): %s in %q", d.Severity, d.Field, d.Source, d.Message, d.Expression)
+}
+
+// specChecker walks a spec and collects diagnostics
+type specChecker struct {
+	fields      []Field
+	options     CheckOptions
+	diagnostics []Diagnostic
+}
+
+// fieldExpression is a condition expression attached to a field
+type fieldExpression struct {
+	source     string
+	expression string
+}
+
+// checkFields checks the expressions of each field and recurses into groups
+func (c *specChecker) checkFields(fields []Field, parentPath []string) {
+	for i := range fields {
+		field := &fields[i]
+		fieldPath := AppendToPath(parentPath, field.Name)
+
+		for _, fe := range fieldExpressions(field) {
+			c.checkExpression(fieldPath, fe)
+		}
+
+		childPath := fieldPath
+		if field.Multiple {
+			childPath = AppendToPath(fieldPath, "*")
+		}
+		c.checkFields(field.Fields, childPath)
+	}
+}
+
+// checkExpression compiles a single expression and records a diagnostic on failure
+func (c *specChecker) checkExpression(fieldPath []string, fe fieldExpression) {
+	limits := DefaultLimits()
+	if c.options.Limits != nil {
+		limits = *c.options.Limits
+	}
+	expr, err := compile(fe.expression, limits)
+	if err != nil {
+		c.report(fieldPath, fe, err)
+		return
+	}
+
+	c.checkPaths(fieldPath, fe, expr.ast)
+	if c.options.Types {
+		c.checkTypes(fieldPath, fe, expr.ast)
+	}
+}
+
+// checkPaths resolves every path in an expression against the spec tree.
+// Relative paths are resolved the same way the evaluator resolves them, with *
+// standing in for the item index of each enclosing repeatable group.
+// Variables must be built in or declared in CheckOptions.Variables.
+func (c *specChecker) checkPaths(fieldPath []string, fe fieldExpression, root ASTNode) {
+	evaluator := newEvaluator(nil, fieldPath)
+
+	Inspect(root, func(node ASTNode) bool {
+		switch n := node.(type) {
+		case *PathNode:
+			path := evaluator.resolvePath(n)
+			if _, message := c.resolveSpecPath(path); message != "" {
+				c.add(fieldPath, fe, n.Position, SeverityError, message)
+			}
+		case *VariableNode:
+			if !c.isVariable(n.Name) {
+				c.add(fieldPath, fe, n.Position, SeverityError, fmt.Sprintf("undefined variable $%s", n.Name))
+			}
+		}
+		return true
+	})
+}
+
+// isVariable reports whether a variable is built in or declared in the options
+func (c *specChecker) isVariable(name string) bool {
+	if IsBuiltinVariable(name) {
+		return true
+	}
+	for _, declared := range c.options.Variables {
+		if declared == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSpecPath walks a resolved path through the spec fields.
+// Returns the field the path names, or an empty field and a description of the problem.
+func (c *specChecker) resolveSpecPath(path []string) (*Field, string) {
+	fields := c.fields
+	var current *Field
+	itemSelected := false
+
+	for i, segment := range path {
+		resolved := PathToString(path[:i+1])
+
+		// Wildcard, index or unique key: only valid on repeatable groups
+		if IsItemSegment(segment) || segment == "*" {
+			if current == nil || !(current.Multiple || current.MultipleOnly) || itemSelected {
+				return nil, fmt.Sprintf("%q in %q is not applied to a repeatable group", segment, resolved)
+			}
+			itemSelected = true
+			continue
+		}
+
+		if current != nil {
+			if current.Multiple && !itemSelected {
+				return nil, fmt.Sprintf("%q is a repeatable group; %q must follow an index or *", PathToString(path[:i]), segment)
+			}
+			if len(current.Fields) == 0 {
+				return nil, fmt.Sprintf("%q is not a group and has no field %q", PathToString(path[:i]), segment)
+			}
+		}
+
+		current = findField(fields, segment)
+		if current == nil {
+			return nil, fmt.Sprintf("reference to unknown field %q", resolved)
+		}
+		fields = current.Fields
+		itemSelected = false
+	}
+
+	return current, ""
+}
+
+// findField finds a field by name in a field list
+func findField(fields []Field, name string) *Field {
+	for i := range fields {
+		if fields[i].Name == name {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// report records a diagnostic for each syntax error of an expression
+func (c *specChecker) report(fieldPath []string, fe fieldExpression, err error) {
+	parseErrs := ParseErrorsOf(err)
+	if parseErrs == nil {
+		c.diagnostics = append(c.diagnostics, Diagnostic{
+			Field:      PathToString(fieldPath),
+			Source:     fe.source,
+			Expression: fe.expression,
+			Severity:   SeverityError,
+			Message:    err.Error(),
+		})
+		return
+	}
+
+	for _, parseErr := range parseErrs {
+		c.add(fieldPath, fe, ASTPosition{Start: parseErr.Start, End: parseErr.End}, SeverityError, parseErr.Message)
+	}
+}
+
+// add records a diagnostic located at a byte range of the expression
+func (c *specChecker) add(fieldPath []string, fe fieldExpression, pos ASTPosition, severity, message string) {
+	line, column := lineColumn(fe.expression, pos.Start)
+	end := pos.End
+	if end > len(fe.expression) {
+		end = len(fe.expression)
+	}
+	length := 0
+	if end > pos.Start {
+		length = utf8.RuneCountInString(fe.expression[pos.Start:end])
+	}
+
+	c.diagnostics = append(c.diagnostics, Diagnostic{
+		Field:      PathToString(fieldPath),
+		Source:     fe.source,
+		Expression: fe.expression,
+		Line:       line,
+		Column:     column,
+		Length:     length,
+		Severity:   severity,
+		Message:    message,
+	})
+}
+
+// fieldExpressions collects the condition expressions of a field in a stable order
+func fieldExpressions(field *Field) []fieldExpression {
+	var expressions []fieldExpression
+
+	if req, ok := field.Required.(string); ok && isConditionExpression(req) {
+		expressions = append(expressions, fieldExpression{"required", req})
+	}
+
+	ruleNames := make([]string, 0, len(field.Rules))
+	for ruleName := range field.Rules {
+		ruleNames = append(ruleNames, ruleName)
+	}
+	sort.Strings(ruleNames)
+
+	for _, ruleName := range ruleNames {
+		strVal, ok := field.Rules[ruleName].(string)
+		if !ok {
+			continue
+		}
+		switch {
+		case ruleName == "required":
+			if isConditionExpression(strVal) {
+				expressions = append(expressions, fieldExpression{"rules.required", strVal})
+			}
+		case ruleName == "match" || ruleName == "pattern":
+			// Regex patterns routinely contain ? and : and are never conditions
+		case isTernaryCandidate(strVal):
+			expressions = append(expressions, fieldExpression{"rules." + ruleName, strVal})
+		}
+	}
+
+	if field.DisplaySwitch != "" {
+		expressions = append(expressions, fieldExpression{"display_switch", field.DisplaySwitch})
+	}
+	if field.Computed != "" {
+		expressions = append(expressions, fieldExpression{"computed", field.Computed})
+	}
+
+	return expressions
+}
@@ -0,0 +1,270 @@
+package validator
+
+import (
+	"strconv"
+	"testing"
+)
+
+// TestSpecCheckParseErrors tests that Spec.Check reports malformed expressions with their location
+func TestSpecCheckParseErrors(t *testing.T) {
+	spec := Spec{
+		Fields: []Field{
+			{Name: "is_sale", Type: "checkbox"},
+			{Name: "price", Type: "number", Required: ".is_sale == "},
+			{Name: "items", Type: "group", Multiple: true, Fields: []Field{
+				{Name: "qty", Type: "number", Rules: map[string]interface{}{
+					"required": ".price > 0 &&",
+					"min":      "...is_sale == 1 ? 1 : 0",
+					"max":      ".qty > ? 10 : 20",
+					"match":    "^(?:[a-z]+):\\d+$",
+				}},
+				{Name: "total", Type: "number", Computed: ".qty * (.price"},
+			}},
+			{Name: "card_number", Type: "text", DisplaySwitch: ".payment_type == 'card"},
+			{Name: "region", Type: "text", Required: ".country == en-us"},
+		},
+	}
+
+	want := []Diagnostic{
+		{Field: "price", Source: "required", Expression: ".is_sale == ", Line: 1, Column: 13, Length: 0, Severity: SeverityError, Message: "expected expression"},
+		{Field: "items.*.qty", Source: "rules.max", Expression: ".qty > ? 10 : 20", Line: 1, Column: 8, Length: 1, Severity: SeverityError, Message: "expected expression"},
+		{Field: "items.*.qty", Source: "rules.required", Expression: ".price > 0 &&", Line: 1, Column: 14, Length: 0, Severity: SeverityError, Message: "expected expression"},
+		{Field: "items.*.total", Source: "computed", Expression: ".qty * (.price", Line: 1, Column: 15, Length: 0, Severity: SeverityError, Message: "expected ')'"},
+		{Field: "card_number", Source: "display_switch", Expression: ".payment_type == 'card", Line: 1, Column: 18, Length: 5, Severity: SeverityError, Message: "unterminated string"},
+		{Field: "region", Source: "required", Expression: ".country == en-us", Line: 1, Column: 15, Length: 1, Severity: SeverityError, Message: `unexpected character "-"; quote a value containing -, or put spaces around - to subtract`},
+	}
+
+	got := spec.Check()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d diagnostics, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Diagnostic %d:\n got  %+v\n want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestSpecCheckValidSpec tests that a well-formed spec produces no diagnostics
+func TestSpecCheckValidSpec(t *testing.T) {
+	spec := Spec{
+		Fields: []Field{
+			{Name: "quantity", Type: "number"},
+			{Name: "unit_price", Type: "number"},
+			{Name: "discount_rate", Type: "number"},
+			{Name: "membership", Type: "select"},
+			{Name: "total_price", Type: "number", Computed: ".quantity * .unit_price"},
+			{Name: "discount", Type: "number", Computed: ".total_price * (.discount_rate / 100) - 1"},
+			{Name: "note", Type: "text", Required: true, Rules: map[string]interface{}{"required": "true"}},
+			{Name: "memo", Type: "text", DisplaySwitch: ".membership in premium,vip && .quantity >= 10"},
+		},
+	}
+
+	if diagnostics := spec.Check(); len(diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", diagnostics)
+	}
+}
+
+// TestArithmetic tests arithmetic operators in expressions
+func TestArithmetic(t *testing.T) {
+	data := map[string]interface{}{"quantity": 3, "unit_price": "1500", "discount_rate": 10, "total": -1}
+
+	cases := []struct {
+		expression string
+		want       interface{}
+	}{
+		{".quantity * .unit_price", float64(4500)},
+		{".quantity * .unit_price * (.discount_rate / 100)", float64(450)},
+		{".unit_price - .quantity * 500", float64(0)},
+		{".total -1", float64(-2)},
+		{".total - -1", float64(0)},
+		{".quantity / 0", nil},
+		{".missing + 1", nil},
+		{".quantity * 2 > 5", true},
+		{".total == -1", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.expression, func(t *testing.T) {
+			expr, err := Compile(tc.expression)
+			if err != nil {
+				t.Fatalf("Compile returned error: %v", err)
+			}
+			if got := expr.EvaluateValue(data, []string{"value"}); got != tc.want {
+				t.Errorf("EvaluateValue = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestSpecCheckPathReferences tests that Spec.Check resolves path references against the spec tree
+func TestSpecCheckPathReferences(t *testing.T) {
+	spec := Spec{
+		Fields: []Field{
+			{Name: "is_sale", Type: "checkbox"},
+			{Name: "common", Type: "group", Fields: []Field{
+				{Name: "is_option", Type: "choice"},
+				{Name: "note", Type: "text", Required: "..is_sale == 1 && .is_option == 1 && .is_optoin == 2"},
+			}},
+			{Name: "items", Type: "group", Multiple: true, Fields: []Field{
+				{Name: "price", Type: "number"},
+				{Name: "options", Type: "group", Multiple: true, Fields: []Field{
+					{Name: "sold_out", Type: "checkbox"},
+				}},
+				{Name: "qty", Type: "number", Rules: map[string]interface{}{
+					"required": "...is_sale == 1 && .price > 0 && ..is_sale == 1",
+				}},
+				{Name: "memo", Type: "text", Required: "any(items.*.options.*.sold_out == 1) && items.0.price > 0"},
+			}},
+			{Name: "summary", Type: "text", DisplaySwitch: "common.*.is_option == 1 || items.price > 0 || is_sale.value == 1 || items.*.options.*.price > 0"},
+		},
+	}
+
+	want := []string{
+		`common.note (required): reference to unknown field "common.is_optoin" at column 38`,
+		`items.*.qty (rules.required): "items" is a repeatable group; "is_sale" must follow an index or * at column 34`,
+		`summary (display_switch): "*" in "common.*" is not applied to a repeatable group at column 1`,
+		`summary (display_switch): "items" is a repeatable group; "price" must follow an index or * at column 28`,
+		`summary (display_switch): "is_sale" is not a group and has no field "value" at column 47`,
+		`summary (display_switch): reference to unknown field "items.*.options.*.price" at column 69`,
+	}
+
+	got := spec.Check()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d diagnostics, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		summary := got[i].Field + " (" + got[i].Source + "): " + got[i].Message + " at column " + strconv.Itoa(got[i].Column)
+		if summary != want[i] {
+			t.Errorf("Diagnostic %d:\n got  %s\n want %s", i, summary, want[i])
+		}
+	}
+}
+
+// TestSpecCheckTypes tests the optional type-inference pass
+func TestSpecCheckTypes(t *testing.T) {
+	spec := Spec{
+		Fields: []Field{
+			{Name: "age", Type: "number"},
+			{Name: "count", Type: "number"},
+			{Name: "is_sale", Type: "checkbox"},
+			{Name: "start_date", Type: "date"},
+			{Name: "membership", Type: "select", Items: map[string]interface{}{"basic": "Basic", "premium": "Premium", "vip": "VIP"}},
+			{Name: "region", Type: "select", Items: map[string]interface{}{"Seoul": map[string]interface{}{"gangnam": "Gangnam"}}},
+			{Name: "brand", Type: "select", Items: map[string]interface{}{"model": "Brand", "method": "getSelectOptions"}},
+			{Name: "name", Type: "text"},
+			{Name: "a", Type: "text", Required: ".age > 'abc' || .count == true || .age >= '18'"},
+			{Name: "b", Type: "text", Required: ".membership in basic, gold || .membership == 'vip' || .region == 'gangnam'"},
+			{Name: "c", Type: "text", Required: ".is_sale == 2 || .is_sale == 'yes' || .start_date > 5 || .start_date > '2024-01-01'"},
+			{Name: "d", Type: "text", Required: ".start_date == .age || .brand == 'anything' || .name == 1 || .name > true"},
+			{Name: "e", Type: "number", Computed: ".age * 'x' + .count"},
+		},
+	}
+
+	if diagnostics := spec.Check(); len(diagnostics) != 0 {
+		t.Fatalf("Expected no diagnostics without the type pass, got %v", diagnostics)
+	}
+
+	want := []string{
+		`a: .age is a number but 'abc' is not numeric; the values are compared as strings`,
+		`a: .count is compared with true: a number is never a boolean`,
+		`b: gold in the list of .membership never matches: not one of the items (basic, premium, vip)`,
+		`c: .is_sale is compared with 2: a checkbox is only ever 0 or 1`,
+		`c: .start_date is a date but 5 is not a date`,
+		`d: .start_date is a date but .age is a number`,
+		`d: boolean true cannot be ordered`,
+		`e: 'x' is not numeric`,
+	}
+
+	got := spec.CheckWithOptions(CheckOptions{Types: true})
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d diagnostics, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i].Severity != SeverityWarning {
+			t.Errorf("Diagnostic %d: expected warning severity, got %q", i, got[i].Severity)
+		}
+		if summary := got[i].Field + ": " + got[i].Message; summary != want[i] {
+			t.Errorf("Diagnostic %d:\n got  %s\n want %s", i, summary, want[i])
+		}
+	}
+}
+
+// TestSpecCheckTypesListItems tests that the literals compared with list fields are
+// checked against their static items
+func TestSpecCheckTypesListItems(t *testing.T) {
+	colors := map[string]interface{}{"red": "Red", "blue": "Blue"}
+	spec := Spec{
+		Fields: []Field{
+			{Name: "colors", Type: "multichoice", Items: colors},
+			{Name: "tags", Type: "tagify", Items: colors},
+			{Name: "sizes", Type: "checkbox", Items: map[string]interface{}{"s": "S", "m": "M"}},
+			{Name: "free", Type: "multichoice"},
+			{Name: "starts_at", Type: "datetime-local"},
+			{Name: "a", Type: "text", Required: ".colors in red, green || .colors == 'blue' || .colors == 'pink'"},
+			{Name: "b", Type: "text", Required: ".tags contains 'red' || .tags contains 'gold' || .sizes contains 'xl' || .free contains 'x'"},
+			{Name: "c", Type: "text", Required: ".sizes in [s, xs] || .sizes == 1 || .starts_at > 'soon'"},
+		},
+	}
+
+	want := []string{
+		`a: green in the list of .colors never matches: not one of the items (blue, red)`,
+		`a: .colors is compared with 'pink': not one of the items (blue, red)`,
+		`b: .tags never contains 'gold': not one of the items (blue, red)`,
+		`b: .sizes never contains 'xl': not one of the items (m, s)`,
+		`c: xs in the list of .sizes never matches: not one of the items (m, s)`,
+		`c: .sizes is compared with 1: not one of the items (m, s)`,
+		`c: .starts_at is a date but 'soon' is not a date`,
+	}
+
+	got := spec.CheckWithOptions(CheckOptions{Types: true})
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d diagnostics, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if summary := got[i].Field + ": " + got[i].Message; summary != want[i] {
+			t.Errorf("Diagnostic %d:\n got  %s\n want %s", i, summary, want[i])
+		}
+	}
+}
+
+// TestSpecCheckReportsEveryParseError tests that each malformed operand gets its own diagnostic
+func TestSpecCheckReportsEveryParseError(t *testing.T) {
+	spec := Spec{
+		Fields: []Field{
+			{Name: "a", Type: "number"},
+			{Name: "b", Type: "text", Required: ".a > && .a < || .a =="},
+		},
+	}
+
+	got := spec.Check()
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 diagnostics, got %v", got)
+	}
+	if caret := got[1].Caret(); caret != ".a > && .a < || .a ==\n             ^^" {
+		t.Errorf("Caret() = %q", caret)
+	}
+	if caret := got[2].Caret(); caret != ".a > && .a < || .a ==\n                     ^" {
+		t.Errorf("Caret() = %q", caret)
+	}
+}
+
+func TestSpecCheckVariables(t *testing.T) {
+	spec := Spec{Fields: []Field{
+		{Name: "password", Type: "password", Required: "$mode == 'create' && $today > '2024-01-01'"},
+		{Name: "admin_note", Type: "text", DisplaySwitch: "'admin' in $usr.roles"},
+	}}
+
+	got := spec.CheckWithOptions(CheckOptions{Variables: []string{"mode", "user"}})
+	want := []Diagnostic{
+		{Field: "admin_note", Source: "display_switch", Expression: "'admin' in $usr.roles", Line: 1, Column: 12, Length: 10, Severity: SeverityError, Message: "undefined variable $usr"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d diagnostics, got %d: %v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Diagnostic %d:\n got  %+v\n want %+v", i, got[i], want[i])
+		}
+	}
+}
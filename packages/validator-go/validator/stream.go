@@ -0,0 +1,382 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ValidateStream validates a JSON object read from dec without decoding it whole.
+// The items of top-level repeatable groups are decoded and validated one at a time
+// as they arrive, and their errors are passed to emit right away; the other fields
+// are decoded into memory and validated once the object ends. Memory is bounded by
+// the largest item and the non-repeatable fields.
+//
+// A group keyed by unique keys, an object instead of a list, is streamed the same
+// way, item by item, but the errors of its items are emitted when the object ends:
+// a key that is not a unique key, which may come last, makes Validate ignore it.
+//
+// The errors are those Validate reports for the decoded object, though errors of
+// streamed items come first. A group is only streamed when the conditions that read
+// it can be evaluated item by item: when no field outside its items reads it, and its
+// items read no other items and no other repeatable group. Items that read other
+// top-level fields, such as a max of "...mode == 'wholesale' ? 10000 : 100", are
+// streamed if those fields precede the group in the stream. Other groups are decoded
+// whole and validated with the rest of the object, so putting such fields first
+// keeps memory bounded but is not needed for correct results. Rule functions
+// registered with WithRule or AddRule may read any field, so they keep every group
+// from being streamed.
+//
+// ValidateStream reports whether the object is valid. It stops at the first decoding
+// error, such as malformed JSON or a value that is not an object, and returns it; the
+// errors emitted until then stand. The decoder is left after the object, so a stream
+// of objects can be validated by calling ValidateStream in a loop.
+func (v *Validator) ValidateStream(dec *json.Decoder, env Env, emit func(ValidationError)) (bool, error) {
+	c := v.config.Load()
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return false, err
+	}
+
+	valid := true
+	report := func(result *ValidationResult) {
+		for _, e := range result.Errors {
+			valid = false
+			emit(e)
+		}
+		result.Errors = result.Errors[:0]
+	}
+
+	data := make(map[string]interface{})
+	result := &ValidationResult{IsValid: true, Errors: []ValidationError{}}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return false, err
+		}
+		key := token.(string)
+
+		fp := c.streamedGroup(key)
+		if fp == nil || !c.plan.streaming.ready(key, data) {
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				return false, err
+			}
+			data[key] = value
+			continue
+		}
+
+		token, err = dec.Token()
+		if err != nil {
+			return false, err
+		}
+		switch token {
+		case json.Delim('['):
+			err = c.streamItems(dec, fp, data, env, result, report)
+		case json.Delim('{'):
+			err = c.streamKeyedItems(dec, fp, data, env, result, report)
+		default:
+			// Not a collection of items, which validation ignores
+			err = skipValue(dec, token)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return false, err
+	}
+
+	// Streamed groups are absent from data, so this validates the remaining fields
+	c.validateFields(c.plan.fields, data, data, []string{}, env, c.parallelism, result)
+	report(result)
+
+	return valid, nil
+}
+
+// streamItems validates the items of a list as they are decoded, up to and including
+// its closing bracket. Conditions of an item find it at its index, in a map that holds
+// only the current item, so that validated items can be collected.
+func (c *config) streamItems(dec *json.Decoder, fp *fieldPlan, data map[string]interface{}, env Env, result *ValidationResult, report func(*ValidationResult)) error {
+	key := fp.field.Name
+	slot := map[string]interface{}{}
+	data[key] = slot
+	fieldPath := fp.pathIn(nil)
+	for i := 0; dec.More(); i++ {
+		var item interface{}
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+
+		if itemMap, ok := item.(map[string]interface{}); ok {
+			index := strconv.Itoa(i)
+			slot[index] = item
+			c.validateFields(fp.children, itemMap, data, AppendToPath(fieldPath, index), env, c.parallelism, result)
+			report(result)
+			delete(slot, index)
+		}
+	}
+	delete(data, key)
+	return expectDelim(dec, ']')
+}
+
+// streamKeyedItems validates the items of a keyed collection as they are decoded, up
+// to and including its closing brace. Items are validated in the order they arrive
+// rather than in key order. Conditions of an item find it at its unique key. Like
+// Validate, which ignores an object with a key that is not a unique key, the errors
+// of the items are held until the object ends and dropped once such a key appears.
+func (c *config) streamKeyedItems(dec *json.Decoder, fp *fieldPlan, data map[string]interface{}, env Env, result *ValidationResult, report func(*ValidationResult)) error {
+	fieldPath := fp.pathIn(nil)
+	keyed := true
+	items := &ValidationResult{IsValid: true, Errors: []ValidationError{}}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		itemKey := token.(string)
+		if !IsUniqueKey(itemKey) {
+			keyed, items.Errors = false, nil
+		}
+		if !keyed {
+			token, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if err := skipValue(dec, token); err != nil {
+				return err
+			}
+			continue
+		}
+		var item interface{}
+		if err := dec.Decode(&item); err != nil {
+			return err
+		}
+
+		if itemMap, ok := item.(map[string]interface{}); ok {
+			data[fp.field.Name] = map[string]interface{}{itemKey: item}
+			itemPath := AppendToPath(fieldPath, itemKey)
+			c.validateFields(fp.children, itemMap, data, itemPath, env, c.parallelism, items)
+		}
+	}
+	delete(data, fp.field.Name)
+	if keyed {
+		result.Errors = append(result.Errors, items.Errors...)
+		report(result)
+	}
+	return expectDelim(dec, '}')
+}
+
+// streamedGroup returns the plan of the top-level repeatable group named key, or nil
+// if the field is not one
+func (c *config) streamedGroup(key string) *fieldPlan {
+	for _, fp := range c.plan.fields {
+		if fp.field.Name == key {
+			if fp.field.Multiple && fp.field.Fields != nil {
+				return fp
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// streamPlan tells which top-level repeatable groups ValidateStream can validate
+// item by item with the results of Validate
+type streamPlan struct {
+	buffered map[string]bool     // Groups read from outside their items, or whose items read other items
+	needs    map[string][]string // Top-level fields the items of a group read
+}
+
+// ready reports whether the group named key can be streamed once the fields in data
+// have been read
+func (sp *streamPlan) ready(key string, data map[string]interface{}) bool {
+	if sp.buffered[key] {
+		return false
+	}
+	for _, name := range sp.needs[key] {
+		if _, ok := data[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldRead is a field a condition or rule reads: a top-level field, or any field if
+// top is empty. inItem is true for reads within the item of the repeatable group the
+// reading field is in.
+type fieldRead struct {
+	top    string
+	inItem bool
+}
+
+// compileStreaming works out from the reads of every field which groups can be
+// streamed. A repeatable group that is read from outside its items is buffered, and
+// so is a group whose items read other items or another repeatable group; reads of
+// other top-level fields become needs. A read of any field buffers every group.
+func (c *config) compileStreaming(fields []*fieldPlan) *streamPlan {
+	sp := &streamPlan{buffered: map[string]bool{}, needs: map[string][]string{}}
+	groups := map[string]bool{}
+	for _, fp := range fields {
+		if fp.field.Multiple && fp.field.Fields != nil {
+			groups[fp.field.Name] = true
+		}
+	}
+
+	builtin := DefaultRules()
+	for _, fp := range fields {
+		top := fp.field.Name
+		c.fieldReads(fp, top, groups[top], 1, builtin, func(read fieldRead) {
+			switch {
+			case read.inItem:
+			case read.top == "":
+				for group := range groups {
+					sp.buffered[group] = true
+				}
+			case groups[read.top]:
+				sp.buffered[read.top] = true
+				if groups[top] {
+					sp.buffered[top] = true
+				}
+			case groups[top]:
+				for _, name := range sp.needs[top] {
+					if name == read.top {
+						return
+					}
+				}
+				sp.needs[top] = append(sp.needs[top], read.top)
+			}
+		})
+	}
+	return sp
+}
+
+// fieldReads calls visit with the reads of the conditions and rules of a field and
+// its children. top is the top-level field the field is in, repeatable whether that
+// is a repeatable group, and depth the length of the data path of the field.
+func (c *config) fieldReads(fp *fieldPlan, top string, repeatable bool, depth int, builtin map[string]RuleFunc, visit func(fieldRead)) {
+	read := func(relative bool, levelsUp int, segments []string) {
+		visit(pathRead(relative, levelsUp, segments, top, repeatable, depth))
+	}
+	expressionReads := func(expr *Expression) {
+		Inspect(expr.AST(), func(node ASTNode) bool {
+			if path, ok := node.(*PathNode); ok {
+				segments := make([]string, len(path.Segments))
+				for i, seg := range path.Segments {
+					if seg.Type == "wildcard" {
+						segments[i] = "*"
+					} else {
+						segments[i] = seg.Value
+					}
+				}
+				read(path.Relative, path.LevelsUp, segments)
+			}
+			return true
+		})
+	}
+	// Only the built-in rules are known to read what they are given; rules that
+	// replace them may read any field of the form data
+	isBuiltin := func(name string, fn RuleFunc) bool {
+		return builtin[name] != nil && reflect.ValueOf(fn).Pointer() == reflect.ValueOf(builtin[name]).Pointer()
+	}
+
+	if fp.requiredExpr != nil {
+		expressionReads(fp.requiredExpr)
+	}
+	if fp.number != nil && !isBuiltin("number", fp.number) {
+		visit(fieldRead{})
+	}
+	for _, rp := range fp.rules {
+		if rp.expr != nil {
+			expressionReads(rp.expr)
+		}
+		switch {
+		case rp.custom != nil:
+			for _, name := range []string{"match", "min", "max"} {
+				if !isBuiltin(name, c.rules[name]) {
+					visit(fieldRead{})
+				}
+			}
+		case !isBuiltin(rp.name, rp.fn):
+			visit(fieldRead{})
+		case rp.name == "equalTo" || rp.name == "notEqual" || rp.name == "enddate":
+			// The first parameter is a path to the field compared with, like in getValueByPath
+			if rp.expr != nil {
+				visit(fieldRead{})
+				continue
+			}
+			if len(rp.params) == 0 || rp.name == "notEqual" && !strings.HasPrefix(rp.params[0], ".") {
+				continue
+			}
+			path := strings.TrimLeft(rp.params[0], ".")
+			if path == "" || strings.Contains(path, "..") {
+				visit(fieldRead{})
+				continue
+			}
+			levelsUp := len(rp.params[0]) - len(path)
+			read(levelsUp > 0, levelsUp, strings.Split(path, "."))
+		}
+	}
+
+	childDepth := depth + 1
+	if fp.field.Multiple && fp.field.Fields != nil {
+		childDepth++ // The item index or unique key
+	}
+	for _, child := range fp.children {
+		c.fieldReads(child, top, repeatable, childDepth, builtin, visit)
+	}
+}
+
+// pathRead returns the read of a path from a field at depth in the top-level field
+// top. Relative paths start from the parent of the field, levelsUp levels higher;
+// in a repeatable group, the item is the second level.
+func pathRead(relative bool, levelsUp int, segments []string, top string, repeatable bool, depth int) fieldRead {
+	if relative {
+		base := depth - 1 - levelsUp
+		if repeatable && base >= 2 {
+			return fieldRead{top: top, inItem: true}
+		}
+		if base >= 1 {
+			return fieldRead{top: top}
+		}
+	}
+	if len(segments) == 0 || segments[0] == "*" {
+		return fieldRead{}
+	}
+	return fieldRead{top: segments[0]}
+}
+
+// expectDelim reads the next token and checks that it is the delimiter want
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != want {
+		return fmt.Errorf("expected %v, got %v at offset %d", want, token, dec.InputOffset())
+	}
+	return nil
+}
+
+// skipValue consumes the rest of a value whose first token has been read
+func skipValue(dec *json.Decoder, first json.Token) error {
+	depth := 0
+	if delim, ok := first.(json.Delim); ok && (delim == '{' || delim == '[') {
+		depth = 1
+	}
+	for depth > 0 {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// stringOperators are the word operators of string matching. The i-prefixed
+// variants ignore case.
+var stringOperators = map[string]bool{
+	"contains":    true,
+	"startsWith":  true,
+	"endsWith":    true,
+	"icontains":   true,
+	"istartsWith": true,
+	"iendsWith":   true,
+}
+
+// isStringOperator reports whether an operator matches strings: a word operator,
+// =~ or !~
+func isStringOperator(operator string) bool {
+	return stringOperators[operator] || operator == "=~" || operator == "!~"
+}
+
+// checkRegex validates the pattern of a =~ or !~ operator when it is a string literal,
+// and keeps it compiled for the expression
+func (p *parser) checkRegex(operator string, right ASTNode) *ParseError {
+	literal, ok := right.(*LiteralNode)
+	if !ok || (operator != "=~" && operator != "!~") || literal.ValueType != "string" {
+		return nil
+	}
+	pattern := toString(literal.Value)
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		message := strings.TrimPrefix(err.Error(), "error parsing regexp: ")
+		return &ParseError{
+			Code:    CodeInvalidRegex,
+			Message: fmt.Sprintf("invalid regular expression: %s", message),
+			Start:   literal.Position.Start,
+			End:     literal.Position.End,
+		}
+	}
+	if p.regexes == nil {
+		p.regexes = map[string]*regexp.Regexp{}
+	}
+	p.regexes[pattern] = re
+	return nil
+}
+
+// evaluateStringOperator applies a string operator. Numbers are matched by their
+// string form; null never matches. On an array value (a multichoice field) contains
+// tests membership of an element, and the other operators match if any element does.
+// Literal patterns are compiled with the expression, others on every evaluation.
+func (e *evaluator) evaluateStringOperator(operator string, value, operand interface{}) bool {
+	if value == nil || operand == nil {
+		return operator == "!~"
+	}
+
+	if operator == "!~" {
+		return !e.evaluateStringOperator("=~", value, operand)
+	}
+
+	if arr, ok := value.([]interface{}); ok {
+		for _, element := range arr {
+			switch operator {
+			case "contains":
+				if isEqual(element, operand) {
+					return true
+				}
+			case "icontains":
+				if element != nil && strings.EqualFold(toString(element), toString(operand)) {
+					return true
+				}
+			default:
+				if e.evaluateStringOperator(operator, element, operand) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	s, pattern := toString(value), toString(operand)
+	switch operator {
+	case "contains":
+		return strings.Contains(s, pattern)
+	case "startsWith":
+		return strings.HasPrefix(s, pattern)
+	case "endsWith":
+		return strings.HasSuffix(s, pattern)
+	case "icontains":
+		return strings.Contains(strings.ToLower(s), strings.ToLower(pattern))
+	case "istartsWith":
+		return strings.HasPrefix(strings.ToLower(s), strings.ToLower(pattern))
+	case "iendsWith":
+		return strings.HasSuffix(strings.ToLower(s), strings.ToLower(pattern))
+	case "=~":
+		re, ok := e.regexes[pattern]
+		if !ok {
+			var err error
+			if re, err = regexp.Compile(pattern); err != nil {
+				return false
+			}
+		}
+		return re.MatchString(s)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,297 @@
+package validator
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the layouts times are written in for the values of temporal field
+// types; times in fields of other types are written in RFC 3339
+var timeLayouts = map[string]string{
+	"date":           "2006-01-02",
+	"datetime":       time.RFC3339,
+	"datetime-local": "2006-01-02T15:04:05",
+	"time":           "15:04:05",
+	"month":          "2006-01",
+}
+
+// timeType is the type of time.Time, which is written as a string instead of a struct
+var timeType = reflect.TypeOf(time.Time{})
+
+// textMarshalerType is the type of encoding.TextMarshaler
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// isTextStruct reports whether values of a struct type are written as a string
+// rather than as their fields: a time.Time, or a type implementing
+// encoding.TextMarshaler, such as the date types of Spec.GenerateGo
+func isTextStruct(t reflect.Type) bool {
+	return t == timeType || t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// ValidateStruct validates a struct, a map, or a pointer to either against the spec.
+// The fields of a struct are named by their form tag, else their json tag, else the
+// Go field name; fields tagged "-" and unexported fields are skipped, omitempty drops
+// zero values, and embedded structs without a name are merged into their parent.
+// Pointers are followed, slices become the items of repeatable groups, a time.Time
+// is written in the layout of its field type, such as 2006-01-02 for date fields,
+// and other structs implementing encoding.TextMarshaler are written as their text.
+//
+// It returns an error if value is not a struct or map, or if it refers to itself
+// through pointers, maps or slices.
+func (v *Validator) ValidateStruct(value interface{}) (*ValidationResult, error) {
+	return v.ValidateStructWithEnv(value, nil)
+}
+
+// ValidateStructWithEnv validates a struct like ValidateStruct, resolving $ variables
+// in conditions from env
+func (v *Validator) ValidateStructWithEnv(value interface{}, env Env) (*ValidationResult, error) {
+	c := v.config.Load()
+	converted, err := c.spec.structData(reflect.ValueOf(value))
+	if err != nil {
+		return nil, fmt.Errorf("cannot validate %T: %w", value, err)
+	}
+	data, ok := converted.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cannot validate %T, want a struct or map", value)
+	}
+	return c.validate(data, env), nil
+}
+
+// structData converts a Go value into the form data Validate takes, or returns an
+// error if the value refers to itself
+func (s Spec) structData(rv reflect.Value) (interface{}, error) {
+	conv := &structConverter{spec: s, visiting: map[structVisit]bool{}}
+	return conv.data(rv, nil)
+}
+
+// structConverter converts Go values into form data, keeping the pointers, maps and
+// slices on the way to the current value to detect cycles, as encoding/json does
+type structConverter struct {
+	spec     Spec
+	visiting map[structVisit]bool
+}
+
+// structVisit identifies a pointer, map or slice being converted. Slices sharing an
+// array but of different lengths are different values.
+type structVisit struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+// enter records a pointer, map or slice on the way to the current value, returning
+// false if it is already on the way, and leave forgets it
+func (conv *structConverter) enter(rv reflect.Value) (structVisit, bool) {
+	visit := structVisit{typ: rv.Type(), ptr: rv.Pointer()}
+	if rv.Kind() == reflect.Slice {
+		visit.len = rv.Len()
+	}
+	if conv.visiting[visit] {
+		return visit, false
+	}
+	conv.visiting[visit] = true
+	return visit, true
+}
+
+// data converts a Go value at a data path into form data: maps, []interface{} lists
+// and values of basic types
+func (conv *structConverter) data(rv reflect.Value, path []string) (interface{}, error) {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Kind() == reflect.Pointer {
+			visit, ok := conv.enter(rv)
+			if !ok {
+				return nil, conv.cycle(rv, path)
+			}
+			defer delete(conv.visiting, visit)
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		if rv.Type() == timeType {
+			t := rv.Interface().(time.Time)
+			if t.IsZero() {
+				return nil, nil
+			}
+			layout, ok := timeLayouts[conv.spec.fieldTypeAt(path)]
+			if !ok {
+				layout = time.RFC3339
+			}
+			return t.Format(layout), nil
+		}
+		if isTextStruct(rv.Type()) {
+			return conv.text(rv, path)
+		}
+		data := make(map[string]interface{})
+		if err := conv.fields(rv, path, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		visit, ok := conv.enter(rv)
+		if !ok {
+			return nil, conv.cycle(rv, path)
+		}
+		defer delete(conv.visiting, visit)
+
+		data := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			value, err := conv.data(iter.Value(), AppendToPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			data[key] = value
+		}
+		return data, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice {
+			if rv.IsNil() {
+				return nil, nil
+			}
+			visit, ok := conv.enter(rv)
+			if !ok {
+				return nil, conv.cycle(rv, path)
+			}
+			defer delete(conv.visiting, visit)
+		}
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			item, err := conv.data(rv.Index(i), AppendToPath(path, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return nil, nil
+	default:
+		if basic, ok := basicValue(rv.Interface()); ok {
+			return basic, nil
+		}
+		return rv.Interface(), nil
+	}
+}
+
+// embedded merges the fields of an embedded struct, or a non-nil pointer to one, into
+// data. merged is false if the field is not such a struct.
+func (conv *structConverter) embedded(fv reflect.Value, path []string, data map[string]interface{}) (merged bool, err error) {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return true, nil
+		}
+		if fv.Elem().Kind() != reflect.Struct || isTextStruct(fv.Elem().Type()) {
+			return false, nil
+		}
+		visit, ok := conv.enter(fv)
+		if !ok {
+			return true, conv.cycle(fv, path)
+		}
+		defer delete(conv.visiting, visit)
+		fv = fv.Elem()
+	}
+	if fv.Kind() != reflect.Struct || isTextStruct(fv.Type()) {
+		return false, nil
+	}
+	return true, conv.fields(fv, path, data)
+}
+
+// text writes a struct implementing encoding.TextMarshaler as its text; empty text
+// is an empty value
+func (conv *structConverter) text(rv reflect.Value, path []string) (interface{}, error) {
+	if !rv.Type().Implements(textMarshalerType) {
+		if !rv.CanAddr() {
+			addressable := reflect.New(rv.Type()).Elem()
+			addressable.Set(rv)
+			rv = addressable
+		}
+		rv = rv.Addr()
+	}
+	text, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return nil, fmt.Errorf("cannot write %s at %q: %w", rv.Type(), PathToString(path), err)
+	}
+	if len(text) == 0 {
+		return nil, nil
+	}
+	return string(text), nil
+}
+
+// cycle returns the error of a value met again inside itself
+func (conv *structConverter) cycle(rv reflect.Value, path []string) error {
+	return fmt.Errorf("encountered a cycle via %s at %q", rv.Type(), PathToString(path))
+}
+
+// fields adds the exported fields of a struct to data
+func (conv *structConverter) fields(rv reflect.Value, path []string, data map[string]interface{}) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		name, omitEmpty, skip := fieldTag(sf)
+		if skip {
+			continue
+		}
+		fv := rv.Field(i)
+
+		if sf.Anonymous && name == "" {
+			if merged, err := conv.embedded(fv, path, data); merged {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+		value, err := conv.data(fv, AppendToPath(path, name))
+		if err != nil {
+			return err
+		}
+		data[name] = value
+	}
+	return nil
+}
+
+// fieldTag reads the name and omitempty option of a struct field from its form tag,
+// or else its json tag. skip is true for fields tagged "-".
+func fieldTag(sf reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag, ok := sf.Tag.Lookup("form")
+	if !ok {
+		tag = sf.Tag.Get("json")
+	}
+	if tag == "-" {
+		return "", false, true
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	for options != "" {
+		var option string
+		option, options, _ = strings.Cut(options, ",")
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
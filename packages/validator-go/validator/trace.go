@@ -0,0 +1,146 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TraceNode is one evaluated sub-expression of a condition.
+// The tree mirrors the AST, but only contains the nodes that were actually evaluated:
+// the skipped side of a short-circuited && or || has no node.
+type TraceNode struct {
+	Kind           string       `json:"kind"`                      // AST node type (Binary, Path, Literal, ...)
+	Expression     string       `json:"expression"`                // Source text of the sub-expression
+	Operator       string       `json:"operator,omitempty"`        // Operator of Binary, Unary, In and Quantifier nodes
+	Path           string       `json:"path,omitempty"`            // Resolved absolute path of Path nodes
+	Result         interface{}  `json:"result"`                    // Value of the sub-expression (the field value for Path nodes)
+	ShortCircuited bool         `json:"short_circuited,omitempty"` // Evaluation stopped before all operands were evaluated
+	Children       []*TraceNode `json:"children,omitempty"`
+}
+
+// tracer builds a TraceNode tree while an evaluator runs
+type tracer struct {
+	source  string
+	root    *TraceNode
+	current *TraceNode
+}
+
+// Explain evaluates the expression and returns the evaluation tree
+func (x *Expression) Explain(formData map[string]interface{}, currentPath []string) *TraceNode {
+	return x.ExplainEnv(formData, currentPath, nil)
+}
+
+// ExplainEnv is like Explain, resolving $ variables from env
+func (x *Expression) ExplainEnv(formData map[string]interface{}, currentPath []string, env Env) *TraceNode {
+	evaluator := x.newEvaluator(formData, currentPath)
+	evaluator.env = env
+	evaluator.tracer = &tracer{source: x.source}
+	evaluator.evaluate(x.ast)
+	return evaluator.tracer.root
+}
+
+// Explain evaluates a condition expression and returns the evaluation tree
+func (cp *ConditionParser) Explain(expression string, formData map[string]interface{}, currentPath []string) (*TraceNode, error) {
+	expr, err := cp.lookup(expression)
+	if err != nil {
+		return nil, err
+	}
+	return expr.Explain(formData, currentPath), nil
+}
+
+// record evaluates a node and appends its trace to the node being evaluated
+func (t *tracer) record(e *evaluator, node ASTNode) interface{} {
+	step := &TraceNode{Kind: node.NodeType(), Expression: t.sourceOf(node)}
+	switch n := node.(type) {
+	case *BinaryNode:
+		step.Operator = n.Operator
+	case *UnaryNode:
+		step.Operator = n.Operator
+	case *InNode:
+		step.Operator = "in"
+		if n.Negated {
+			step.Operator = "not in"
+		}
+	case *QuantifierNode:
+		step.Operator = n.Quantifier
+	case *PathNode:
+		step.Path = PathToString(e.bindPath(e.resolvePath(n)))
+	case *VariableNode:
+		step.Path = formatVariable(n)
+	}
+
+	parent := t.current
+	if parent == nil {
+		t.root = step
+	} else {
+		parent.Children = append(parent.Children, step)
+	}
+
+	t.current = step
+	step.Result = e.evaluateNode(node)
+	t.current = parent
+
+	return step.Result
+}
+
+// sourceOf returns the source text of a node
+func (t *tracer) sourceOf(node ASTNode) string {
+	pos := node.GetPosition()
+	if pos.Start < 0 || pos.End > len(t.source) || pos.Start >= pos.End {
+		return ""
+	}
+	return t.source[pos.Start:pos.End]
+}
+
+// shortCircuit marks the node being evaluated as stopped early
+func (e *evaluator) shortCircuit() {
+	if e.tracer != nil && e.tracer.current != nil {
+		e.tracer.current.ShortCircuited = true
+	}
+}
+
+// String renders the trace as an indented tree, one sub-expression per line:
+//
+//	.payment_type == 'card' && ..is_sale => false (short-circuited)
+//	  .payment_type == 'card' => false
+//	    .payment_type [payment_type] => "cash"
+//	    'card' => "card"
+func (n *TraceNode) String() string {
+	var sb strings.Builder
+	n.write(&sb, 0)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (n *TraceNode) write(sb *strings.Builder, depth int) {
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(n.Expression)
+	if n.Path != "" {
+		sb.WriteString(" [" + n.Path + "]")
+	}
+	sb.WriteString(" => " + formatTraceValue(n.Result))
+	if n.ShortCircuited {
+		sb.WriteString(" (short-circuited)")
+	}
+	sb.WriteString("\n")
+
+	for _, child := range n.Children {
+		child.write(sb, depth+1)
+	}
+}
+
+// formatTraceValue formats a value with strings quoted so that "1" and 1 can be told apart
+func formatTraceValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("%q", v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,881 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TranspileJS compiles an AST into a self-contained JavaScript expression with the
+// same semantics as the Go evaluator (truthiness, loose equality, path resolution,
+// wildcards and quantifiers). The expression evaluates to an object:
+//
+//	{
+//	  evaluate: function (data, currentPath, env) { ... }, // raw value, like Expression.EvaluateValue
+//	  test: function (data, currentPath, env) { ... }      // boolean, like Expression.Evaluate
+//	}
+//
+// currentPath is an array of path segments (e.g. ["items", "0", "price"]) and env
+// an optional object of $ variables. Date and duration literals are not supported,
+// nor, with TranspileSpec, paths to date and time fields.
+func TranspileJS(node ASTNode, options ...TranspileOption) (string, error) {
+	code, err := transpileWith(node, jsTarget{}, options)
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(jsRuntime, "/*EXPRESSION*/", code, 1), nil
+}
+
+// TranspilePHP compiles an AST into a self-contained PHP expression (an anonymous
+// class instance, PHP 8.0+) with the same semantics as the Go evaluator:
+//
+//	$condition = <output>;
+//	$condition->evaluate(array|object $data, array $currentPath = [], array $env = []): mixed
+//	$condition->test(array|object $data, array $currentPath = [], array $env = []): bool
+//
+// Both JSON objects decoded as associative arrays and stdClass objects are supported.
+// Since PHP arrays do not distinguish an empty list from an empty object, an empty
+// array is treated as an empty list.
+func TranspilePHP(node ASTNode, options ...TranspileOption) (string, error) {
+	code, err := transpileWith(node, phpTarget{}, options)
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(phpRuntime, "/*EXPRESSION*/", code, 1), nil
+}
+
+// TranspileOption configures TranspileJS and TranspilePHP
+type TranspileOption func(*transpileConfig)
+
+// transpileConfig holds the settings of a transpilation
+type transpileConfig struct {
+	spec *Spec
+}
+
+// TranspileSpec declares the spec an expression is evaluated against. Go compares the
+// values of date, datetime, time and month fields as times (see NewSpecConditionParser)
+// while the generated runtimes have no notion of field types and would compare the
+// strings, so an expression that reads such a field is rejected. Pass it for every
+// expression of a spec.
+func TranspileSpec(spec Spec) TranspileOption {
+	return func(c *transpileConfig) {
+		c.spec = &spec
+	}
+}
+
+// transpileWith checks that the generated code can agree with the Go evaluator and
+// renders the node
+func transpileWith(node ASTNode, t transpileTarget, options []TranspileOption) (string, error) {
+	var c transpileConfig
+	for _, option := range options {
+		option(&c)
+	}
+	if c.spec != nil {
+		var temporal *PathNode
+		Inspect(node, func(child ASTNode) bool {
+			if path, ok := child.(*PathNode); ok && temporal == nil && c.spec.temporalPath(path) {
+				temporal = path
+			}
+			return temporal == nil
+		})
+		if temporal != nil {
+			return "", fmt.Errorf("path %s reads a date or time field, which the transpiler does not support", formatPath(temporal))
+		}
+	}
+	return transpile(node, t)
+}
+
+// temporalPath reports whether a path can read a date, datetime, time or month field.
+// A relative path is looked up from every group of the spec, since the group it starts
+// from depends on the current path.
+func (s Spec) temporalPath(node *PathNode) bool {
+	path := make([]string, len(node.Segments))
+	for i, seg := range node.Segments {
+		if seg.Type == "wildcard" {
+			path[i] = "*"
+		} else {
+			path[i] = seg.Value
+		}
+	}
+	if !node.Relative {
+		return temporalFieldTypes[s.fieldTypeAt(path)]
+	}
+
+	groups := [][]Field{s.Fields}
+	for len(groups) > 0 {
+		fields := groups[0]
+		groups = groups[1:]
+		if temporalFieldTypes[Spec{Fields: fields}.fieldTypeAt(path)] {
+			return true
+		}
+		for _, field := range fields {
+			if len(field.Fields) > 0 {
+				groups = append(groups, field.Fields)
+			}
+		}
+	}
+	return false
+}
+
+// transpileTarget renders runtime calls and values in a target language
+type transpileTarget interface {
+	call(name string, args ...string) string
+	value(v interface{}) string
+	array(items []string) string
+	thunk(body string) string
+}
+
+// transpile renders a node as an expression of the target language
+func transpile(node ASTNode, t transpileTarget) (string, error) {
+	switch n := node.(type) {
+	case *LiteralNode:
+		if n.ValueType == "date" || n.ValueType == "duration" {
+			return "", fmt.Errorf("%s literals are not supported by the transpiler", n.ValueType)
+		}
+		return t.value(n.Value), nil
+	case *PathNode:
+		return transpilePath(n, t), nil
+	case *VariableNode:
+		segments := make([]string, len(n.Segments))
+		for i, seg := range n.Segments {
+			segments[i] = t.value(seg.Value)
+		}
+		return t.call("variable", t.value(n.Name), t.array(segments)), nil
+	case *GroupNode:
+		return transpile(n.Expression, t)
+	case *UnaryNode:
+		operand, err := transpile(n.Operand, t)
+		if err != nil {
+			return "", err
+		}
+		if n.Operator != "!" {
+			return "", fmt.Errorf("unsupported unary operator %q", n.Operator)
+		}
+		return "!" + t.call("truthy", operand), nil
+	case *BinaryNode:
+		left, err := transpile(n.Left, t)
+		if err != nil {
+			return "", err
+		}
+		right, err := transpile(n.Right, t)
+		if err != nil {
+			return "", err
+		}
+		switch n.Operator {
+		case "&&", "||":
+			return "(" + t.call("truthy", left) + " " + n.Operator + " " + t.call("truthy", right) + ")", nil
+		case "==":
+			return t.call("equal", left, right), nil
+		case "!=":
+			return "!" + t.call("equal", left, right), nil
+		case ">", ">=", "<", "<=":
+			return "(" + t.call("compare", left, right) + " " + n.Operator + " 0)", nil
+		case "+", "-", "*", "/":
+			return t.call("arithmetic", t.value(n.Operator), left, right), nil
+		case "contains", "startsWith", "endsWith", "icontains", "istartsWith", "iendsWith", "=~", "!~":
+			return t.call("strOp", t.value(n.Operator), left, right), nil
+		case "intersects", "subsetOf":
+			return t.call("setOp", t.value(n.Operator), left, right), nil
+		default:
+			return "", fmt.Errorf("unsupported binary operator %q", n.Operator)
+		}
+	case *InNode:
+		value, err := transpile(n.Value, t)
+		if err != nil {
+			return "", err
+		}
+		items := make([]string, len(n.List))
+		for i, item := range n.List {
+			if items[i], err = transpile(item, t); err != nil {
+				return "", err
+			}
+		}
+		return t.call("inList", value, t.array(items), t.value(n.Negated)), nil
+	case *ArrayNode:
+		elements := make([]string, len(n.Elements))
+		for i, element := range n.Elements {
+			var err error
+			if elements[i], err = transpile(element, t); err != nil {
+				return "", err
+			}
+		}
+		return t.call("values", t.array(elements)), nil
+	case *TernaryNode:
+		condition, err := transpile(n.Condition, t)
+		if err != nil {
+			return "", err
+		}
+		trueValue, err := transpile(n.TrueValue, t)
+		if err != nil {
+			return "", err
+		}
+		falseValue, err := transpile(n.FalseValue, t)
+		if err != nil {
+			return "", err
+		}
+		return "(" + t.call("truthy", condition) + " ? " + trueValue + " : " + falseValue + ")", nil
+	case *QuantifierNode:
+		expression, err := transpile(n.Expression, t)
+		if err != nil {
+			return "", err
+		}
+		// Candidate domains in evaluation order; the runtime picks the one with the most wildcards
+		var paths []string
+		Inspect(n.Expression, func(child ASTNode) bool {
+			switch c := child.(type) {
+			case *QuantifierNode:
+				return false
+			case *PathNode:
+				paths = append(paths, t.call("resolve", pathArgs(c, t)...))
+			}
+			return true
+		})
+		return t.call("quantify", t.value(n.Quantifier), t.array(paths), t.thunk(expression)), nil
+	default:
+		return "", fmt.Errorf("unsupported AST node %T", node)
+	}
+}
+
+// transpilePath renders a path lookup
+func transpilePath(node *PathNode, t transpileTarget) string {
+	return t.call("valueAt", t.call("resolve", pathArgs(node, t)...))
+}
+
+// pathArgs renders the arguments of the runtime resolve function
+func pathArgs(node *PathNode, t transpileTarget) []string {
+	segments := make([]string, len(node.Segments))
+	for i, seg := range node.Segments {
+		if seg.Type == "wildcard" {
+			segments[i] = t.value("*")
+		} else {
+			segments[i] = t.value(seg.Value)
+		}
+	}
+	return []string{t.value(node.Relative), strconv.Itoa(node.LevelsUp), t.array(segments)}
+}
+
+// jsTarget renders JavaScript
+type jsTarget struct{}
+
+func (jsTarget) call(name string, args ...string) string {
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (jsTarget) value(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "null"
+	}
+	return string(data)
+}
+
+func (jsTarget) array(items []string) string {
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+func (jsTarget) thunk(body string) string {
+	return "function () { return " + body + "; }"
+}
+
+// phpTarget renders PHP inside the runtime class
+type phpTarget struct{}
+
+func (phpTarget) call(name string, args ...string) string {
+	return "$this->" + name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (phpTarget) value(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case string:
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(val) + "'"
+	default:
+		return "'" + toString(val) + "'"
+	}
+}
+
+func (phpTarget) array(items []string) string {
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+func (phpTarget) thunk(body string) string {
+	return "fn () => " + body
+}
+
+// jsRuntime is the JavaScript evaluator; each helper mirrors its Go counterpart
+const jsRuntime = `(function () {
+  "use strict";
+  var NUMBER = /^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$/;
+  var INDEX = /^[+-]?\d+$/;
+  var UNIQUE_KEY = /^__[a-z0-9]{13}__$/;
+  var ctx;
+
+  function isNil(v) { return v === null || v === undefined; }
+  function truthy(v) {
+    if (isNil(v)) return false;
+    if (typeof v === "boolean") return v;
+    if (typeof v === "number") return v !== 0;
+    if (typeof v === "string") return v !== "" && v !== "0" && v.toLowerCase() !== "false";
+    if (Array.isArray(v)) return v.length > 0;
+    return true;
+  }
+  function toNumber(v) {
+    if (typeof v === "number") return v;
+    if (typeof v !== "string") return null;
+    if (NUMBER.test(v)) { var n = Number(v); return isFinite(n) ? n : null; }
+    if (/^[+-]?inf(inity)?$/i.test(v)) return v.charAt(0) === "-" ? -Infinity : Infinity;
+    if (/^[+-]?nan$/i.test(v)) return NaN;
+    return null;
+  }
+  function toBoolean(v) {
+    if (typeof v === "boolean") return v;
+    if (typeof v === "number") return v !== 0;
+    if (typeof v === "string") { var s = v.toLowerCase(); return s === "true" || s === "1" || s === "yes"; }
+    return false;
+  }
+  function toStr(v) {
+    if (typeof v === "string") return v;
+    if (typeof v === "number" || typeof v === "boolean") return String(v);
+    return "";
+  }
+  function equal(a, b) {
+    if (isNil(a) || isNil(b)) return isNil(a) && isNil(b);
+    var na = toNumber(a), nb = toNumber(b);
+    if (na !== null && nb !== null) return na === nb;
+    if (typeof a === "boolean") return a === toBoolean(b);
+    if (typeof b === "boolean") return toBoolean(a) === b;
+    return toStr(a) === toStr(b);
+  }
+  function compare(a, b) {
+    var na = toNumber(a), nb = toNumber(b);
+    if (na !== null && nb !== null) return na < nb ? -1 : (na > nb ? 1 : 0);
+    var sa = toStr(a), sb = toStr(b);
+    return sa < sb ? -1 : (sa > sb ? 1 : 0);
+  }
+  function arithmetic(op, a, b) {
+    var na = toNumber(a), nb = toNumber(b);
+    if (na === null || nb === null) return null;
+    switch (op) {
+      case "+": return na + nb;
+      case "-": return na - nb;
+      case "*": return na * nb;
+      case "/": return nb === 0 ? null : na / nb;
+    }
+    return null;
+  }
+  function values(list) {
+    var out = [];
+    for (var i = 0; i < list.length; i++) out = out.concat(Array.isArray(list[i]) ? list[i] : [list[i]]);
+    return out;
+  }
+  function has(list, value) {
+    for (var i = 0; i < list.length; i++) if (equal(list[i], value)) return true;
+    return false;
+  }
+  function inList(value, list, negated) {
+    var candidates = Array.isArray(value) ? value : [value], items = values(list);
+    for (var i = 0; i < items.length; i++) {
+      if (has(candidates, items[i])) return !negated;
+    }
+    return negated;
+  }
+  function setOp(op, a, b) {
+    a = isNil(a) ? [] : values([a]);
+    b = isNil(b) ? [] : values([b]);
+    for (var i = 0; i < a.length; i++) {
+      var found = has(b, a[i]);
+      if (op === "intersects" && found) return true;
+      if (op === "subsetOf" && !found) return false;
+    }
+    return op === "subsetOf";
+  }
+  function regex(pattern) {
+    var flags = "", m = /^\(\?([a-zA-Z]+)\)/.exec(pattern);
+    if (m) { flags = m[1].replace(/[^ims]/g, ""); pattern = pattern.slice(m[0].length); }
+    return new RegExp(pattern, flags);
+  }
+  function strOp(op, value, operand) {
+    if (isNil(value) || isNil(operand)) return op === "!~";
+    if (op === "!~") return !strOp("=~", value, operand);
+    if (Array.isArray(value)) {
+      for (var i = 0; i < value.length; i++) {
+        var hit = op === "contains" ? equal(value[i], operand)
+          : op === "icontains" ? !isNil(value[i]) && toStr(value[i]).toLowerCase() === toStr(operand).toLowerCase()
+          : strOp(op, value[i], operand);
+        if (hit) return true;
+      }
+      return false;
+    }
+    var s = toStr(value), p = toStr(operand);
+    switch (op) {
+      case "contains": return s.indexOf(p) !== -1;
+      case "startsWith": return s.slice(0, p.length) === p;
+      case "endsWith": return s.length >= p.length && s.slice(s.length - p.length) === p;
+      case "icontains": return s.toLowerCase().indexOf(p.toLowerCase()) !== -1;
+      case "istartsWith": return s.slice(0, p.length).toLowerCase() === p.toLowerCase();
+      case "iendsWith": return s.length >= p.length && s.slice(s.length - p.length).toLowerCase() === p.toLowerCase();
+      case "=~": try { return regex(p).test(s); } catch (e) { return false; }
+    }
+    return false;
+  }
+  function lookup(current, path) {
+    for (var i = 0; i < path.length; i++) {
+      if (isNil(current)) return null;
+      if (Array.isArray(current)) {
+        if (!INDEX.test(path[i])) return null;
+        var idx = parseInt(path[i], 10);
+        if (idx < 0 || idx >= current.length) return null;
+        current = current[idx];
+      } else if (typeof current === "object") {
+        current = Object.prototype.hasOwnProperty.call(current, path[i]) ? current[path[i]] : null;
+      } else {
+        return null;
+      }
+    }
+    return isNil(current) ? null : current;
+  }
+  function pad(n) {
+    return (n < 10 ? "0" : "") + n;
+  }
+  function clock() {
+    var date = new Date(Math.floor(Date.now() / 1000) * 1000);
+    var zone = typeof ctx.env.timezone === "string" && ctx.env.timezone !== "" ? ctx.env.timezone : "UTC";
+    var parts = {};
+    try {
+      new Intl.DateTimeFormat("en-US", {
+        timeZone: zone, hourCycle: "h23", year: "numeric", month: "2-digit", day: "2-digit",
+        hour: "2-digit", minute: "2-digit", second: "2-digit"
+      }).formatToParts(date).forEach(function (part) { parts[part.type] = part.value; });
+    } catch (e) {
+      return date.toISOString().replace(/\.\d+Z$/, "Z");
+    }
+    var local = parts.year + "-" + parts.month + "-" + parts.day + "T" + parts.hour + ":" + parts.minute + ":" + parts.second;
+    var offset = Math.round((Date.parse(local + "Z") - date.getTime()) / 60000);
+    if (offset === 0) return local + "Z";
+    var abs = Math.abs(offset);
+    return local + (offset < 0 ? "-" : "+") + pad(Math.floor(abs / 60)) + ":" + pad(abs % 60);
+  }
+  function variable(name, path) {
+    if (Object.prototype.hasOwnProperty.call(ctx.env, name)) return lookup(ctx.env[name], path);
+    if (name === "timezone") return lookup("UTC", path);
+    if (name !== "now" && name !== "today") return null;
+    if (ctx.now === null) ctx.now = clock();
+    return lookup(name === "now" ? ctx.now : ctx.now.slice(0, 10), path);
+  }
+  function resolve(relative, levelsUp, segments) {
+    if (!relative) return segments;
+    var base = Math.max(ctx.currentPath.length - 1 - levelsUp, 0);
+    return ctx.currentPath.slice(0, base).concat(segments);
+  }
+  function boundIndex(arrayPath) {
+    for (var i = ctx.anchors.length - 1; i >= -1; i--) {
+      var context = i >= 0 ? ctx.anchors[i] : ctx.currentPath;
+      if (context.length <= arrayPath.length) continue;
+      var matches = true;
+      for (var j = 0; j < arrayPath.length; j++) {
+        if (arrayPath[j] !== context[j]) { matches = false; break; }
+      }
+      var segment = context[arrayPath.length];
+      if (matches && (INDEX.test(segment) || UNIQUE_KEY.test(segment))) return segment;
+    }
+    return null;
+  }
+  function uniqueKeys(obj) {
+    var keys = Object.keys(obj);
+    if (keys.length === 0) return null;
+    for (var i = 0; i < keys.length; i++) {
+      if (!UNIQUE_KEY.test(keys[i])) return null;
+    }
+    return keys.sort();
+  }
+  function valueAt(path) {
+    var w = path.indexOf("*");
+    if (w === -1) return lookup(ctx.data, path);
+    var arrayPath = path.slice(0, w), rest = path.slice(w + 1);
+    var idx = boundIndex(arrayPath);
+    if (idx !== null) return valueAt(arrayPath.concat([idx], rest));
+    var arr = lookup(ctx.data, arrayPath);
+    if (Array.isArray(arr)) {
+      for (var i = 0; i < arr.length; i++) {
+        var v = valueAt(arrayPath.concat([String(i)], rest));
+        if (!isNil(v)) return v;
+      }
+      return null;
+    }
+    if (arr !== null && typeof arr === "object") {
+      var keys = uniqueKeys(arr);
+      if (keys === null) return rest.length > 0 ? lookup(arr, rest) : arr;
+      for (var k = 0; k < keys.length; k++) {
+        var kv = valueAt(arrayPath.concat([keys[k]], rest));
+        if (!isNil(kv)) return kv;
+      }
+    }
+    return null;
+  }
+  function expand(path) {
+    var w = path.indexOf("*");
+    if (w === -1) return [path];
+    var arrayPath = path.slice(0, w), rest = path.slice(w + 1);
+    var arr = lookup(ctx.data, arrayPath), result = [], segments = null;
+    if (Array.isArray(arr)) segments = arr.map(function (_, i) { return String(i); });
+    else if (arr !== null && typeof arr === "object") segments = uniqueKeys(arr);
+    if (segments === null) return result;
+    for (var i = 0; i < segments.length; i++) {
+      result = result.concat(expand(arrayPath.concat([segments[i]], rest)));
+    }
+    return result;
+  }
+  function quantify(quantifier, paths, fn) {
+    var domain = null, most = 0;
+    for (var i = 0; i < paths.length; i++) {
+      var count = paths[i].filter(function (s) { return s === "*"; }).length;
+      if (count > most) { domain = paths[i]; most = count; }
+    }
+    if (domain === null) {
+      var single = truthy(fn());
+      return quantifier === "none" ? !single : single;
+    }
+    var anchors = expand(domain);
+    for (var k = 0; k < anchors.length; k++) {
+      ctx.anchors.push(anchors[k]);
+      var result = truthy(fn());
+      ctx.anchors.pop();
+      if (quantifier === "any" && result) return true;
+      if (quantifier === "all" && !result) return false;
+      if (quantifier === "none" && result) return false;
+    }
+    return quantifier !== "any";
+  }
+  function evaluate(data, currentPath, env) {
+    ctx = { data: data || {}, currentPath: (currentPath || []).map(String), env: env || {}, now: null, anchors: [] };
+    return /*EXPRESSION*/;
+  }
+
+  return {
+    evaluate: evaluate,
+    test: function (data, currentPath, env) { return truthy(evaluate(data, currentPath, env)); }
+  };
+})()`
+
+// phpRuntime is the PHP evaluator; each helper mirrors its Go counterpart
+const phpRuntime = `new class {
+    private const NUMBER = '/^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$/';
+    private const INDEX = '/^[+-]?\d+$/';
+    private const UNIQUE_KEY = '/^__[a-z0-9]{13}__$/';
+    private mixed $data = [];
+    private array $currentPath = [];
+    private array $env = [];
+    private ?string $now = null;
+    private array $anchors = [];
+
+    public function evaluate(array|object $data, array $currentPath = [], array $env = []): mixed
+    {
+        $this->data = $data;
+        $this->currentPath = array_map('strval', $currentPath);
+        $this->env = $env;
+        $this->now = null;
+        $this->anchors = [];
+        return /*EXPRESSION*/;
+    }
+
+    public function test(array|object $data, array $currentPath = [], array $env = []): bool
+    {
+        return $this->truthy($this->evaluate($data, $currentPath, $env));
+    }
+
+    private function isList(array $v): bool
+    {
+        return $v === [] || array_keys($v) === range(0, count($v) - 1);
+    }
+
+    private function truthy(mixed $v): bool
+    {
+        if ($v === null) return false;
+        if (is_bool($v)) return $v;
+        if (is_int($v) || is_float($v)) return $v != 0;
+        if (is_string($v)) return $v !== '' && $v !== '0' && strtolower($v) !== 'false';
+        if (is_array($v) && $this->isList($v)) return count($v) > 0;
+        return true;
+    }
+
+    private function toNumber(mixed $v): ?float
+    {
+        if (is_int($v) || is_float($v)) return (float) $v;
+        if (!is_string($v)) return null;
+        if (preg_match(self::NUMBER, $v)) {
+            $n = (float) $v;
+            return is_finite($n) ? $n : null;
+        }
+        if (preg_match('/^[+-]?inf(inity)?$/i', $v)) return $v[0] === '-' ? -INF : INF;
+        if (preg_match('/^[+-]?nan$/i', $v)) return NAN;
+        return null;
+    }
+
+    private function toBoolean(mixed $v): bool
+    {
+        if (is_bool($v)) return $v;
+        if (is_int($v) || is_float($v)) return $v != 0;
+        if (is_string($v)) return in_array(strtolower($v), ['true', '1', 'yes'], true);
+        return false;
+    }
+
+    private function toStr(mixed $v): string
+    {
+        if (is_string($v)) return $v;
+        if (is_bool($v)) return $v ? 'true' : 'false';
+        if (is_int($v)) return (string) $v;
+        if (is_float($v)) {
+            if (is_finite($v) && floor($v) == $v && abs($v) < 1e15) return sprintf('%.0f', $v);
+            return (string) json_encode($v);
+        }
+        return '';
+    }
+
+    private function equal(mixed $a, mixed $b): bool
+    {
+        if ($a === null || $b === null) return $a === null && $b === null;
+        $na = $this->toNumber($a);
+        $nb = $this->toNumber($b);
+        if ($na !== null && $nb !== null) return $na === $nb;
+        if (is_bool($a)) return $a === $this->toBoolean($b);
+        if (is_bool($b)) return $this->toBoolean($a) === $b;
+        return $this->toStr($a) === $this->toStr($b);
+    }
+
+    private function compare(mixed $a, mixed $b): int
+    {
+        $na = $this->toNumber($a);
+        $nb = $this->toNumber($b);
+        if ($na !== null && $nb !== null) return $na < $nb ? -1 : ($na > $nb ? 1 : 0);
+        return max(-1, min(1, strcmp($this->toStr($a), $this->toStr($b))));
+    }
+
+    private function arithmetic(string $op, mixed $a, mixed $b): ?float
+    {
+        $na = $this->toNumber($a);
+        $nb = $this->toNumber($b);
+        if ($na === null || $nb === null) return null;
+        switch ($op) {
+            case '+': return $na + $nb;
+            case '-': return $na - $nb;
+            case '*': return $na * $nb;
+            case '/': return $nb == 0 ? null : $na / $nb;
+        }
+        return null;
+    }
+
+    private function values(array $list): array
+    {
+        $out = [];
+        foreach ($list as $item) {
+            array_push($out, ...(is_array($item) && $this->isList($item) ? $item : [$item]));
+        }
+        return $out;
+    }
+
+    private function has(array $list, mixed $value): bool
+    {
+        foreach ($list as $element) {
+            if ($this->equal($element, $value)) return true;
+        }
+        return false;
+    }
+
+    private function inList(mixed $value, array $list, bool $negated): bool
+    {
+        $candidates = is_array($value) && $this->isList($value) ? $value : [$value];
+        foreach ($this->values($list) as $item) {
+            if ($this->has($candidates, $item)) return !$negated;
+        }
+        return $negated;
+    }
+
+    private function setOp(string $op, mixed $a, mixed $b): bool
+    {
+        $a = $a === null ? [] : $this->values([$a]);
+        $b = $b === null ? [] : $this->values([$b]);
+        foreach ($a as $element) {
+            $found = $this->has($b, $element);
+            if ($op === 'intersects' && $found) return true;
+            if ($op === 'subsetOf' && !$found) return false;
+        }
+        return $op === 'subsetOf';
+    }
+
+    private function regex(string $pattern): string
+    {
+        $flags = '';
+        if (preg_match('/^\(\?([a-zA-Z]+)\)/', $pattern, $m)) {
+            $flags = preg_replace('/[^ims]/', '', $m[1]);
+            $pattern = substr($pattern, strlen($m[0]));
+        }
+        return '~' . str_replace('~', '\~', $pattern) . '~u' . $flags;
+    }
+
+    private function strOp(string $op, mixed $value, mixed $operand): bool
+    {
+        if ($value === null || $operand === null) return $op === '!~';
+        if ($op === '!~') return !$this->strOp('=~', $value, $operand);
+        if (is_array($value) && $this->isList($value)) {
+            foreach ($value as $element) {
+                $hit = match ($op) {
+                    'contains' => $this->equal($element, $operand),
+                    'icontains' => $element !== null && mb_strtolower($this->toStr($element)) === mb_strtolower($this->toStr($operand)),
+                    default => $this->strOp($op, $element, $operand),
+                };
+                if ($hit) return true;
+            }
+            return false;
+        }
+        $s = $this->toStr($value);
+        $p = $this->toStr($operand);
+        switch ($op) {
+            case 'contains': return str_contains($s, $p);
+            case 'startsWith': return str_starts_with($s, $p);
+            case 'endsWith': return str_ends_with($s, $p);
+            case 'icontains': return str_contains(mb_strtolower($s), mb_strtolower($p));
+            case 'istartsWith': return str_starts_with(mb_strtolower($s), mb_strtolower($p));
+            case 'iendsWith': return str_ends_with(mb_strtolower($s), mb_strtolower($p));
+            case '=~': return @preg_match($this->regex($p), $s) === 1;
+        }
+        return false;
+    }
+
+    private function lookup(mixed $current, array $path): mixed
+    {
+        foreach ($path as $segment) {
+            if (is_object($current)) $current = get_object_vars($current);
+            if (!is_array($current)) return null;
+            if ($this->isList($current)) {
+                if (!preg_match(self::INDEX, $segment)) return null;
+                $current = $current[(int) $segment] ?? null;
+            } else {
+                $current = array_key_exists($segment, $current) ? $current[$segment] : null;
+            }
+        }
+        return $current;
+    }
+
+    private function variable(string $name, array $path): mixed
+    {
+        if (array_key_exists($name, $this->env)) return $this->lookup($this->env[$name], $path);
+        if ($name === 'timezone') return $this->lookup('UTC', $path);
+        if ($name !== 'now' && $name !== 'today') return null;
+        $this->now ??= $this->clock();
+        return $this->lookup($name === 'now' ? $this->now : substr($this->now, 0, 10), $path);
+    }
+
+    private function clock(): string
+    {
+        $zone = $this->env['timezone'] ?? null;
+        try {
+            $now = new \DateTimeImmutable('now', new \DateTimeZone(is_string($zone) && $zone !== '' ? $zone : 'UTC'));
+        } catch (\Exception) {
+            $now = new \DateTimeImmutable('now', new \DateTimeZone('UTC'));
+        }
+        return $now->format($now->getOffset() === 0 ? 'Y-m-d\\TH:i:s\\Z' : 'Y-m-d\\TH:i:sP');
+    }
+
+    private function resolve(bool $relative, int $levelsUp, array $segments): array
+    {
+        if (!$relative) return $segments;
+        $base = max(count($this->currentPath) - 1 - $levelsUp, 0);
+        return array_merge(array_slice($this->currentPath, 0, $base), $segments);
+    }
+
+    private function boundIndex(array $arrayPath): ?string
+    {
+        $contexts = array_merge([$this->currentPath], $this->anchors);
+        for ($i = count($contexts) - 1; $i >= 0; $i--) {
+            $context = $contexts[$i];
+            if (count($context) > count($arrayPath)
+                && array_slice($context, 0, count($arrayPath)) === $arrayPath
+                && (preg_match(self::INDEX, $context[count($arrayPath)])
+                    || preg_match(self::UNIQUE_KEY, $context[count($arrayPath)]))) {
+                return $context[count($arrayPath)];
+            }
+        }
+        return null;
+    }
+
+    private function uniqueKeys(array $arr): ?array
+    {
+        if (!$arr) return null;
+        $keys = array_map('strval', array_keys($arr));
+        foreach ($keys as $key) {
+            if (!preg_match(self::UNIQUE_KEY, $key)) return null;
+        }
+        sort($keys, SORT_STRING);
+        return $keys;
+    }
+
+    private function valueAt(array $path): mixed
+    {
+        $w = array_search('*', $path, true);
+        if ($w === false) return $this->lookup($this->data, $path);
+        $arrayPath = array_slice($path, 0, $w);
+        $rest = array_slice($path, $w + 1);
+        $idx = $this->boundIndex($arrayPath);
+        if ($idx !== null) return $this->valueAt(array_merge($arrayPath, [$idx], $rest));
+        $arr = $this->lookup($this->data, $arrayPath);
+        if (is_object($arr)) $arr = get_object_vars($arr);
+        if (!is_array($arr)) return null;
+        $segments = $this->isList($arr) ? array_keys($arr) : $this->uniqueKeys($arr);
+        if ($segments === null) return $rest ? $this->lookup($arr, $rest) : $arr;
+        foreach ($segments as $i) {
+            $v = $this->valueAt(array_merge($arrayPath, [(string) $i], $rest));
+            if ($v !== null) return $v;
+        }
+        return null;
+    }
+
+    private function expand(array $path): array
+    {
+        $w = array_search('*', $path, true);
+        if ($w === false) return [$path];
+        $arrayPath = array_slice($path, 0, $w);
+        $rest = array_slice($path, $w + 1);
+        $arr = $this->lookup($this->data, $arrayPath);
+        if (is_object($arr)) $arr = get_object_vars($arr);
+        if (!is_array($arr)) return [];
+        $segments = $this->isList($arr) ? array_keys($arr) : $this->uniqueKeys($arr);
+        if ($segments === null) return [];
+        $result = [];
+        foreach ($segments as $i) {
+            $result = array_merge($result, $this->expand(array_merge($arrayPath, [(string) $i], $rest)));
+        }
+        return $result;
+    }
+
+    private function quantify(string $quantifier, array $paths, callable $fn): bool
+    {
+        $domain = null;
+        $most = 0;
+        foreach ($paths as $path) {
+            $count = count(array_keys($path, '*', true));
+            if ($count > $most) {
+                $domain = $path;
+                $most = $count;
+            }
+        }
+        if ($domain === null) {
+            $single = $this->truthy($fn());
+            return $quantifier === 'none' ? !$single : $single;
+        }
+        foreach ($this->expand($domain) as $anchor) {
+            $this->anchors[] = $anchor;
+            $result = $this->truthy($fn());
+            array_pop($this->anchors);
+            if ($quantifier === 'any' && $result) return true;
+            if ($quantifier === 'all' && !$result) return false;
+            if ($quantifier === 'none' && $result) return false;
+        }
+        return $quantifier !== 'any';
+    }
+}`
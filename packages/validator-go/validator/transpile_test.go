@@ -0,0 +1,283 @@
+package validator
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// transpileCases are evaluated by both the Go evaluator and the transpiled code
+var transpileCases = []struct {
+	expression  string
+	currentPath []string
+}{
+	{".payment_type == 'card' && ..is_sale", []string{"order", "card_number"}},
+	{".status in active, pending", []string{"status"}},
+	{".status not in active, pending", []string{"status"}},
+	{"items.*.qty > 2", []string{"note"}},
+	{"items.*.qty > 2", []string{"items", "0", "qty"}},
+	{"any(items.*.qty > 2)", []string{"note"}},
+	{"all(items.*.options.*.sold_out == 0)", []string{"note"}},
+	{"none(items.*.status == 'refunded')", []string{"note"}},
+	{"any(items.*.status == 'pending' && items.*.qty == 0)", []string{"note"}},
+	{"..is_sale == 1 ? .qty * 2 + 1 : 'none'", []string{"items", "1", "qty"}},
+	{".price / .zero", []string{"price"}},
+	{"!.flag && .empty == null", []string{"flag"}},
+	{".flag == false && .text == 'False'", []string{"flag"}},
+	{".num_str == 10 && .num_str > 9 && .text > 'Fa'", []string{"num_str"}},
+	{".yes == true", []string{"yes"}},
+	{"only.*.name == 'x'", []string{"note"}},
+	{".missing.deep == null", []string{"missing"}},
+	{"...is_sale", []string{"order", "card_number"}},
+	{"$missing.deep == null && $today != null && .status in $missing, pending", []string{"status"}},
+	{".status startsWith 'pend' && .text icontains 'ALS' && .status !~ '^a' && .num_str =~ '^\\\\d+$'", []string{"status"}},
+	{".tags contains 'b' && .tags iendsWith 'C' && !(.empty contains '') && .note endsWith ''", []string{"tags"}},
+	{".status in .tags, pending && .tags in c, d && .tags not in [] && .num_str in [10]", []string{"tags"}},
+	{".tags intersects [$missing, 'c'] && .tags subsetOf [a, b, c, d] && !(.tags subsetOf .status) && .empty subsetOf []", []string{"tags"}},
+	{"keyed.*.qty", []string{"note"}},
+	{"keyed.*.qty + ...is_sale", []string{"keyed", "__bbbbbbbbbbbb2__", "qty"}},
+	{"any(keyed.*.qty > 2) && all(keyed.*.qty >= 1) && !none(keyed.*.qty == 5)", []string{"note"}},
+}
+
+// transpileEnvCases are evaluated like transpileCases with an environment. They read
+// the clock, so only $today is compared by value and $now by its offset.
+var transpileEnvCases = []struct {
+	expression string
+	env        Env
+}{
+	{"$timezone", nil},
+	{"$now endsWith 'Z' && $now startsWith $today", nil},
+	{"$timezone", Env{"timezone": "Asia/Tokyo"}},
+	{"$today", Env{"timezone": "Asia/Tokyo"}},
+	{"$now endsWith '+09:00' && $now startsWith $today", Env{"timezone": "Asia/Tokyo"}},
+	{"$now =~ '-0[45]:00$'", Env{"timezone": "America/New_York"}},
+	{"$now endsWith '+05:45' ? $today : null", Env{"timezone": "Asia/Kathmandu"}},
+	{"$now endsWith 'Z' && $timezone", Env{"timezone": "Nowhere/Unknown"}},
+}
+
+// transpileData is the form data shared by transpileCases
+var transpileData = map[string]interface{}{
+	"is_sale": 1,
+	"order":   map[string]interface{}{"payment_type": "card", "card_number": ""},
+	"status":  "pending",
+	"items": []interface{}{
+		map[string]interface{}{"qty": 0, "status": "pending", "options": []interface{}{map[string]interface{}{"sold_out": 0}}},
+		map[string]interface{}{"qty": 3, "status": "paid", "options": []interface{}{map[string]interface{}{"sold_out": 1}}},
+	},
+	"price":   "10",
+	"zero":    0,
+	"flag":    "0",
+	"empty":   nil,
+	"text":    "False",
+	"num_str": "10",
+	"yes":     "yes",
+	"only":    map[string]interface{}{"name": "x"},
+	"note":    "",
+	"tags":    []interface{}{"a", "b", "c"},
+	"keyed": map[string]interface{}{
+		"__bbbbbbbbbbbb2__": map[string]interface{}{"qty": 5},
+		"__aaaaaaaaaaaa1__": map[string]interface{}{"qty": 1},
+	},
+}
+
+// TestTranspileJS tests that transpiled JavaScript agrees with the Go evaluator
+func TestTranspileJS(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+
+	// Round-trip the data through JSON so both sides see the same values
+	raw, _ := json.Marshal(transpileData)
+	var data map[string]interface{}
+	json.Unmarshal(raw, &data)
+
+	var script strings.Builder
+	script.WriteString("var data = " + string(raw) + ";\nvar results = [];\n")
+	for _, tc := range transpileCases {
+		code, err := TranspileJS(MustCompile(tc.expression).AST())
+		if err != nil {
+			t.Fatalf("TranspileJS(%q) returned error: %v", tc.expression, err)
+		}
+		currentPath, _ := json.Marshal(tc.currentPath)
+		script.WriteString("var c = " + code + ";\n")
+		script.WriteString("results.push([c.evaluate(data, " + string(currentPath) + "), c.test(data, " + string(currentPath) + ")]);\n")
+	}
+	for _, tc := range transpileEnvCases {
+		code, err := TranspileJS(MustCompile(tc.expression).AST())
+		if err != nil {
+			t.Fatalf("TranspileJS(%q) returned error: %v", tc.expression, err)
+		}
+		env, _ := json.Marshal(tc.env)
+		script.WriteString("var c = " + code + ";\n")
+		script.WriteString("results.push([c.evaluate(data, [], " + string(env) + "), c.test(data, [], " + string(env) + ")]);\n")
+	}
+	script.WriteString("console.log(JSON.stringify(results));\n")
+
+	file := filepath.Join(t.TempDir(), "conditions.js")
+	if err := os.WriteFile(file, []byte(script.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command(node, file).CombinedOutput()
+	if err != nil {
+		t.Fatalf("node failed: %v\n%s", err, output)
+	}
+
+	var got []interface{}
+	if err := json.Unmarshal(output, &got); err != nil {
+		t.Fatalf("Unexpected node output: %s", output)
+	}
+	want := transpileResults(data)
+	for i, tc := range transpileCases {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("%q at %v: JavaScript = %v, Go = %v", tc.expression, tc.currentPath, got[i], want[i])
+		}
+	}
+	for i, tc := range transpileEnvCases {
+		if i += len(transpileCases); !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("%q with %v: JavaScript = %v, Go = %v", tc.expression, tc.env, got[i], want[i])
+		}
+	}
+}
+
+// transpileResults evaluates transpileCases and transpileEnvCases with the Go evaluator, as JSON values:
+// the raw value and the boolean result of each case
+func transpileResults(data map[string]interface{}) []interface{} {
+	var results []interface{}
+	for _, tc := range transpileCases {
+		expr := MustCompile(tc.expression)
+		results = append(results, []interface{}{expr.EvaluateValue(data, tc.currentPath), expr.Evaluate(data, tc.currentPath)})
+	}
+	for _, tc := range transpileEnvCases {
+		value, _ := MustCompile(tc.expression).EvalEnv(data, nil, tc.env)
+		results = append(results, []interface{}{value, isTruthy(value)})
+	}
+	raw, _ := json.Marshal(results)
+	var normalized []interface{}
+	json.Unmarshal(raw, &normalized)
+	return normalized
+}
+
+// TestTranspilePHPRuntime tests that transpiled PHP agrees with the Go evaluator, for
+// data decoded both as associative arrays and as stdClass objects
+func TestTranspilePHPRuntime(t *testing.T) {
+	php, err := exec.LookPath("php")
+	if err != nil {
+		t.Skip("php is not installed")
+	}
+
+	raw, _ := json.Marshal(transpileData)
+	var data map[string]interface{}
+	json.Unmarshal(raw, &data)
+
+	var script strings.Builder
+	script.WriteString("<?php\n$json = <<<'JSON'\n" + string(raw) + "\nJSON;\n")
+	script.WriteString("$inputs = [json_decode($json, true), json_decode($json)];\n$results = [[], []];\n")
+	for _, tc := range transpileCases {
+		code, err := TranspilePHP(MustCompile(tc.expression).AST())
+		if err != nil {
+			t.Fatalf("TranspilePHP(%q) returned error: %v", tc.expression, err)
+		}
+		currentPath, _ := json.Marshal(tc.currentPath)
+		script.WriteString("$c = " + code + ";\n")
+		script.WriteString("foreach ($inputs as $i => $data) {\n")
+		script.WriteString("    $results[$i][] = [$c->evaluate($data, " + string(currentPath) + "), $c->test($data, " + string(currentPath) + ")];\n}\n")
+	}
+	for _, tc := range transpileEnvCases {
+		code, err := TranspilePHP(MustCompile(tc.expression).AST())
+		if err != nil {
+			t.Fatalf("TranspilePHP(%q) returned error: %v", tc.expression, err)
+		}
+		env, _ := json.Marshal(tc.env)
+		script.WriteString("$c = " + code + ";\n$env = json_decode('" + string(env) + "', true) ?? [];\n")
+		script.WriteString("foreach ($inputs as $i => $data) {\n")
+		script.WriteString("    $results[$i][] = [$c->evaluate($data, [], $env), $c->test($data, [], $env)];\n}\n")
+	}
+	script.WriteString("echo json_encode($results);\n")
+
+	file := filepath.Join(t.TempDir(), "conditions.php")
+	if err := os.WriteFile(file, []byte(script.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command(php, file).CombinedOutput()
+	if err != nil {
+		t.Fatalf("php failed: %v\n%s", err, output)
+	}
+
+	var got [2][]interface{}
+	if err := json.Unmarshal(output, &got); err != nil {
+		t.Fatalf("Unexpected php output: %s", output)
+	}
+	want := transpileResults(data)
+	for input, results := range got {
+		if len(results) != len(want) {
+			t.Fatalf("Expected %d results for input %d, got %s", len(want), input, output)
+		}
+		for i, tc := range transpileCases {
+			if !reflect.DeepEqual(results[i], want[i]) {
+				t.Errorf("%q at %v with input %d: PHP = %v, Go = %v", tc.expression, tc.currentPath, input, results[i], want[i])
+			}
+		}
+		for i, tc := range transpileEnvCases {
+			if i += len(transpileCases); !reflect.DeepEqual(results[i], want[i]) {
+				t.Errorf("%q with %v and input %d: PHP = %v, Go = %v", tc.expression, tc.env, input, results[i], want[i])
+			}
+		}
+	}
+}
+
+// TestTranspileTemporal tests that expressions the Go evaluator reads as times are
+// rejected rather than transpiled to string comparisons
+func TestTranspileTemporal(t *testing.T) {
+	spec := Spec{Fields: []Field{
+		{Name: "start_date", Type: "date"},
+		{Name: "name", Type: "text"},
+		{Name: "shifts", Type: "group", Multiple: true, Fields: []Field{
+			{Name: "starts_at", Type: "time"},
+			{Name: "label", Type: "text"},
+		}},
+	}}
+
+	for _, expression := range []string{
+		".start_date > 2024-01-01",
+		".name == 'x' && $today > .name ? 7d : 0",
+		"start_date > .name",
+		"shifts.*.starts_at == null",
+		"any(shifts.*.starts_at > ..name)",
+		".starts_at != null",
+	} {
+		_, errJS := TranspileJS(MustCompile(expression).AST(), TranspileSpec(spec))
+		_, errPHP := TranspilePHP(MustCompile(expression).AST(), TranspileSpec(spec))
+		if errJS == nil || errPHP == nil {
+			t.Errorf("Expected %q to be rejected, got %v, %v", expression, errJS, errPHP)
+		}
+	}
+
+	for _, expression := range []string{".name == 'x'", "shifts.*.label contains 'a'", "..label"} {
+		if _, err := TranspileJS(MustCompile(expression).AST(), TranspileSpec(spec)); err != nil {
+			t.Errorf("Expected %q to transpile, got %v", expression, err)
+		}
+	}
+}
+
+// TestTranspilePHP tests the shape of the transpiled PHP code
+func TestTranspilePHP(t *testing.T) {
+	code, err := TranspilePHP(MustCompile("any(items.*.qty > 2) && .name != 'it\\'s' ? 1 : null").AST())
+	if err != nil {
+		t.Fatalf("TranspilePHP returned error: %v", err)
+	}
+
+	want := `return ($this->truthy(($this->truthy($this->quantify('any', [$this->resolve(false, 0, ['items', '*', 'qty'])], ` +
+		`fn () => ($this->compare($this->valueAt($this->resolve(false, 0, ['items', '*', 'qty'])), 2) > 0))) && ` +
+		`$this->truthy(!$this->equal($this->valueAt($this->resolve(true, 0, ['name'])), 'it\'s')))) ? 1 : null);`
+	if !strings.Contains(code, want) {
+		t.Errorf("TranspilePHP output does not contain\n%s\ngot\n%s", want, code)
+	}
+	if !strings.HasPrefix(code, "new class {") {
+		t.Error("Expected TranspilePHP to produce an anonymous class instance")
+	}
+}
@@ -0,0 +1,351 @@
+package validator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Inferred expression types
+const (
+	typeUnknown  = "unknown"
+	typeNumber   = "number"
+	typeBoolean  = "boolean"
+	typeString   = "string"
+	typeDate     = "date"
+	typeDuration = "duration"
+	typeNull     = "null"
+	typeArray    = "array"
+	typeGroup    = "group"
+)
+
+// exprType is the statically inferred type of an expression
+type exprType struct {
+	kind    string
+	field   *Field       // set for path operands that resolve to a spec field
+	literal *LiteralNode // set for literal operands
+	node    ASTNode
+}
+
+// typeChecker infers expression types from spec field types and reports
+// comparisons whose operands can never match
+type typeChecker struct {
+	checker   *specChecker
+	fieldPath []string
+	fe        fieldExpression
+	evaluator *evaluator
+}
+
+// checkTypes runs type inference over an expression and records warnings
+func (c *specChecker) checkTypes(fieldPath []string, fe fieldExpression, root ASTNode) {
+	tc := &typeChecker{
+		checker:   c,
+		fieldPath: fieldPath,
+		fe:        fe,
+		evaluator: newEvaluator(nil, fieldPath),
+	}
+	tc.infer(root)
+}
+
+// infer returns the type of a node, checking its operands along the way
+func (tc *typeChecker) infer(node ASTNode) exprType {
+	switch n := node.(type) {
+	case *LiteralNode:
+		return exprType{kind: n.ValueType, literal: n, node: n}
+	case *PathNode:
+		field, _ := tc.checker.resolveSpecPath(tc.evaluator.resolvePath(n))
+		if field == nil {
+			return exprType{kind: typeUnknown, node: n}
+		}
+		return exprType{kind: fieldKind(field), field: field, node: n}
+	case *GroupNode:
+		return tc.infer(n.Expression)
+	case *UnaryNode:
+		tc.infer(n.Operand)
+		return exprType{kind: typeBoolean, node: n}
+	case *QuantifierNode:
+		tc.infer(n.Expression)
+		return exprType{kind: typeBoolean, node: n}
+	case *TernaryNode:
+		tc.infer(n.Condition)
+		trueType := tc.infer(n.TrueValue)
+		falseType := tc.infer(n.FalseValue)
+		if trueType.kind == falseType.kind {
+			return exprType{kind: trueType.kind, node: n}
+		}
+		return exprType{kind: typeUnknown, node: n}
+	case *ArrayNode:
+		for _, element := range n.Elements {
+			tc.infer(element)
+		}
+		return exprType{kind: typeArray, node: n}
+	case *InNode:
+		value := tc.infer(n.Value)
+		for _, item := range n.List {
+			tc.checkMembership(value, tc.infer(item))
+		}
+		return exprType{kind: typeBoolean, node: n}
+	case *BinaryNode:
+		left := tc.infer(n.Left)
+		right := tc.infer(n.Right)
+		switch n.Operator {
+		case "==", "!=":
+			tc.checkEquality(left, right)
+			tc.checkEquality(right, left)
+			tc.checkFieldPair(left, right)
+			return exprType{kind: typeBoolean, node: n}
+		case ">", ">=", "<", "<=":
+			tc.checkOrdering(left, right)
+			tc.checkOrdering(right, left)
+			tc.checkFieldPair(left, right)
+			return exprType{kind: typeBoolean, node: n}
+		case "+", "-", "*", "/":
+			tc.checkArithmetic(left)
+			tc.checkArithmetic(right)
+			return exprType{kind: arithmeticKind(n.Operator, left.kind, right.kind), node: n}
+		case "contains":
+			tc.checkContains(left, right)
+			return exprType{kind: typeBoolean, node: n}
+		default:
+			return exprType{kind: typeBoolean, node: n}
+		}
+	default:
+		return exprType{kind: typeUnknown, node: node}
+	}
+}
+
+// checkEquality warns when a field is compared with a literal it can never equal
+func (tc *typeChecker) checkEquality(operand, other exprType) {
+	if operand.field == nil {
+		return
+	}
+
+	if other.literal != nil {
+		if reason := literalMismatch(operand, other.literal); reason != "" {
+			tc.warn(other.node, fmt.Sprintf("%s is compared with %s: %s", tc.describe(operand), tc.describe(other), reason))
+		}
+	}
+}
+
+// checkContains warns when a list field with static items is tested for a literal
+// that is not one of them
+func (tc *typeChecker) checkContains(operand, other exprType) {
+	if operand.field == nil || operand.kind != typeArray || other.literal == nil {
+		return
+	}
+	if reason := literalMismatch(operand, other.literal); reason != "" {
+		tc.warn(other.node, fmt.Sprintf("%s never contains %s: %s", tc.describe(operand), tc.describe(other), reason))
+	}
+}
+
+// checkOrdering warns when an ordering comparison mixes incompatible operands
+func (tc *typeChecker) checkOrdering(operand, other exprType) {
+	if operand.literal != nil && operand.kind == typeBoolean {
+		tc.warn(operand.node, fmt.Sprintf("boolean %s cannot be ordered", tc.describe(operand)))
+		return
+	}
+	if operand.field == nil {
+		return
+	}
+
+	if other.literal != nil {
+		switch {
+		case operand.kind == typeNumber && other.kind == typeString && !isNumericLiteral(other.literal):
+			tc.warn(other.node, fmt.Sprintf("%s is a number but %s is not numeric; the values are compared as strings", tc.describe(operand), tc.describe(other)))
+		case operand.kind == typeDate && (other.kind == typeNumber || other.kind == typeDuration || other.kind == typeString && !isDateLiteral(other.literal)):
+			tc.warn(other.node, fmt.Sprintf("%s is a date but %s is not a date", tc.describe(operand), tc.describe(other)))
+		}
+	}
+}
+
+// checkFieldPair warns when two fields of kinds that never coerce into each other are compared
+func (tc *typeChecker) checkFieldPair(left, right exprType) {
+	if left.field != nil && right.field != nil && incompatibleKinds(left.kind, right.kind) {
+		tc.warn(left.node, fmt.Sprintf("%s is a %s but %s is a %s", tc.describe(left), left.kind, tc.describe(right), right.kind))
+	}
+}
+
+// checkArithmetic warns when an arithmetic operand can never be a number
+func (tc *typeChecker) checkArithmetic(operand exprType) {
+	switch {
+	case operand.literal != nil && operand.kind == typeString && !isNumericLiteral(operand.literal),
+		operand.literal != nil && operand.kind == typeBoolean:
+		tc.warn(operand.node, fmt.Sprintf("%s is not numeric", tc.describe(operand)))
+	case operand.field != nil && (operand.kind == typeArray || operand.kind == typeGroup):
+		tc.warn(operand.node, fmt.Sprintf("%s is a %s and cannot be used in arithmetic", tc.describe(operand), operand.kind))
+	}
+}
+
+// arithmeticKind returns the type of an arithmetic result: date - date is a duration,
+// date ± duration a date, and a duration scaled by a number a duration
+func arithmeticKind(operator, left, right string) string {
+	switch {
+	case left == typeDate && right == typeDate && operator == "-":
+		return typeDuration
+	case left == typeDate && right == typeDuration:
+		return typeDate
+	case left == typeDuration || right == typeDuration:
+		if operator == "/" && left == right {
+			return typeNumber
+		}
+		return typeDuration
+	default:
+		return typeNumber
+	}
+}
+
+// isDateLiteral reports whether a string literal can be read as a date
+func isDateLiteral(literal *LiteralNode) bool {
+	_, ok := parseDateTime(toString(literal.Value), time.UTC)
+	return ok
+}
+
+// checkMembership warns when an 'in' list entry can never match the tested field
+func (tc *typeChecker) checkMembership(value, item exprType) {
+	if value.field == nil || item.literal == nil {
+		return
+	}
+	if reason := literalMismatch(value, item.literal); reason != "" {
+		tc.warn(item.node, fmt.Sprintf("%s in the list of %s never matches: %s", tc.describe(item), tc.describe(value), reason))
+	}
+}
+
+// warn records a type warning located at a node
+func (tc *typeChecker) warn(node ASTNode, message string) {
+	tc.checker.add(tc.fieldPath, tc.fe, *node.GetPosition(), SeverityWarning, message)
+}
+
+// describe returns the source text of an operand for use in messages
+func (tc *typeChecker) describe(t exprType) string {
+	pos := t.node.GetPosition()
+	if pos.Start < 0 || pos.End > len(tc.fe.expression) || pos.Start >= pos.End {
+		return "operand"
+	}
+	return tc.fe.expression[pos.Start:pos.End]
+}
+
+// literalMismatch explains why a literal can never equal the value of a field,
+// or returns an empty string if it can
+func literalMismatch(field exprType, literal *LiteralNode) string {
+	if literal.ValueType == typeNull {
+		return ""
+	}
+
+	// Fields with static items only ever hold item keys, one or a list of them
+	if keys, ok := itemKeys(field.field); ok {
+		for _, key := range keys {
+			if isEqual(key, literal.Value) {
+				return ""
+			}
+		}
+		return "not one of the items (" + strings.Join(keys, ", ") + ")"
+	}
+
+	switch field.kind {
+	case typeNumber:
+		if literal.ValueType == typeBoolean {
+			return "a number is never a boolean"
+		}
+		if literal.ValueType == typeString && !isNumericLiteral(literal) {
+			return "the string is not numeric"
+		}
+	case typeBoolean:
+		switch literal.ValueType {
+		case typeNumber:
+			if num, _ := toFloat64(literal.Value); num != 0 && num != 1 {
+				return "a checkbox is only ever 0 or 1"
+			}
+		case typeString:
+			switch strings.ToLower(toString(literal.Value)) {
+			case "", "0", "1", "true", "false", "yes", "no":
+			default:
+				return "a checkbox is only ever checked or unchecked"
+			}
+		}
+	case typeDate:
+		if literal.ValueType != typeDate && (literal.ValueType != typeString || !isDateLiteral(literal)) {
+			return "the value is not a date"
+		}
+	}
+
+	return ""
+}
+
+// incompatibleKinds reports whether values of two field kinds can never compare meaningfully
+func incompatibleKinds(a, b string) bool {
+	if a == b || a == typeUnknown || b == typeUnknown || a == typeString || b == typeString {
+		return false
+	}
+	scalar := func(kind string) bool {
+		return kind == typeNumber || kind == typeBoolean || kind == typeDate
+	}
+	if scalar(a) && scalar(b) {
+		// Checkboxes hold 0/1 and compare fine with numbers
+		return !(a == typeBoolean && b == typeNumber || a == typeNumber && b == typeBoolean)
+	}
+	return a == typeGroup || b == typeGroup
+}
+
+// isNumericLiteral checks if a literal is a number or a numeric string
+func isNumericLiteral(literal *LiteralNode) bool {
+	_, ok := toFloat64(literal.Value)
+	return ok
+}
+
+// fieldKind maps a spec field type to the type of its value
+func fieldKind(field *Field) string {
+	if len(field.Fields) > 0 && !field.Multiple {
+		return typeGroup
+	}
+
+	switch field.Type {
+	case "number", "range":
+		return typeNumber
+	case "checkbox":
+		if _, ok := itemKeys(field); ok {
+			return typeArray
+		}
+		return typeBoolean
+	case "date", "datetime", "datetime-local", "time", "month":
+		return typeDate
+	case "multichoice", "tagify":
+		return typeArray
+	case "group":
+		if field.Multiple {
+			return typeArray
+		}
+		return typeGroup
+	case "":
+		return typeUnknown
+	default:
+		if field.Multiple {
+			return typeArray
+		}
+		return typeString
+	}
+}
+
+// itemKeys returns the sorted option values of a field with static items.
+// Grouped options are flattened; dynamic sources (model/method) have no static keys.
+func itemKeys(field *Field) ([]string, bool) {
+	if field == nil || len(field.Items) == 0 {
+		return nil, false
+	}
+	if _, dynamic := field.Items["model"]; dynamic {
+		return nil, false
+	}
+
+	var keys []string
+	for key, label := range field.Items {
+		if group, ok := label.(map[string]interface{}); ok {
+			for groupKey := range group {
+				keys = append(keys, groupKey)
+			}
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, true
+}
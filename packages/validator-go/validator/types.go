@@ -1,22 +1,27 @@
 package validator
 
+import "regexp"
+
 // Spec represents the form specification
 type Spec struct {
-	Fields []Field          `json:"fields"`
-	Rules  map[string]Rule  `json:"rules,omitempty"`
+	Fields []Field         `json:"fields"`
+	Rules  map[string]Rule `json:"rules,omitempty"`
 }
 
 // Field represents a form field definition
 type Field struct {
-	Name         string                 `json:"name"`
-	Type         string                 `json:"type"`
-	Label        string                 `json:"label,omitempty"`
-	Required     interface{}            `json:"required,omitempty"` // bool or string (condition)
-	Rules        map[string]interface{} `json:"rules,omitempty"`
-	Messages     map[string]string      `json:"messages,omitempty"`
-	Fields       []Field                `json:"fields,omitempty"`   // for nested/group fields
-	Multiple     bool                   `json:"multiple,omitempty"` // for repeatable groups (array)
-	MultipleOnly bool                   `json:"-"`                  // for "only" mode (single object treated like array for wildcards)
+	Name          string                 `json:"name"`
+	Type          string                 `json:"type"`
+	Label         string                 `json:"label,omitempty"`
+	Required      interface{}            `json:"required,omitempty"`       // bool or string (condition)
+	DisplaySwitch string                 `json:"display_switch,omitempty"` // condition for showing the field
+	Computed      string                 `json:"computed,omitempty"`       // expression the field value is computed from
+	Items         map[string]interface{} `json:"items,omitempty"`          // option values to labels for select/choice/multichoice
+	Rules         map[string]interface{} `json:"rules,omitempty"`
+	Messages      map[string]string      `json:"messages,omitempty"`
+	Fields        []Field                `json:"fields,omitempty"`   // for nested/group fields
+	Multiple      bool                   `json:"multiple,omitempty"` // for repeatable groups (array)
+	MultipleOnly  bool                   `json:"-"`                  // for "only" mode (single object treated like array for wildcards)
 }
 
 // Rule represents a custom rule definition
@@ -39,8 +44,27 @@ type ValidationError struct {
 	Rule    string      `json:"rule"`
 	Message string      `json:"message"`
 	Value   interface{} `json:"value,omitempty"`
+	Trace   *TraceNode  `json:"trace,omitempty"` // Evaluation of the condition behind the error (see Validator.SetTraceConditions)
+}
+
+// Diagnostic describes a problem found in a spec by Spec.Check
+type Diagnostic struct {
+	Field      string `json:"field"`      // Field path, with * for items of repeatable groups
+	Source     string `json:"source"`     // Where the expression is used (required, display_switch, rules.min, computed)
+	Expression string `json:"expression"` // The expression source text
+	Line       int    `json:"line"`       // 1-based line of the problem in the expression (0 if unknown)
+	Column     int    `json:"column"`     // 1-based character column of the problem within the line (0 if unknown)
+	Length     int    `json:"length"`     // Length of the problem in characters
+	Severity   string `json:"severity"`   // SeverityError or SeverityWarning
+	Message    string `json:"message"`
 }
 
+// Diagnostic severities
+const (
+	SeverityError   = "error"   // The expression cannot work as written
+	SeverityWarning = "warning" // The expression works but likely not as intended
+)
+
 // RuleFunc is the signature for custom validation rules
 // Returns nil if valid, or pointer to error message if invalid
 type RuleFunc func(value interface{}, params []string, allData map[string]interface{}, context *ValidationContext) *string
@@ -50,6 +74,9 @@ type ValidationContext struct {
 	CurrentPath []string               // Current field path
 	FormData    map[string]interface{} // All form data
 	FieldDef    *Field                 // Current field definition
+	Env         Env                    // Variables passed to ValidateWithEnv
+
+	patterns map[string]*regexp.Regexp // Compiled match patterns of the spec
 }
 
 // TokenType represents the type of a lexer token
@@ -83,6 +110,14 @@ const (
 	TokenQuestion
 	TokenColon
 	TokenComma
+	TokenPlus
+	TokenMinus
+	TokenSlash
+	TokenVariable // $name; Value holds the name without the $
+	TokenDate     // 2024-01-10 or 2024-01-10T09:00:00+09:00
+	TokenDuration // 7d, 1.5h, 30m
+	TokenMatch    // =~
+	TokenNotMatch // !~
 	TokenWhitespace
 	TokenInvalid
 )
@@ -105,17 +140,17 @@ type TokenPosition struct {
 
 // ASTNode is the interface for all AST nodes
 type ASTNode interface {
-	nodeType() string
-	getPosition() *ASTPosition
+	NodeType() string          // Node kind as used in the JSON encoding (Binary, Unary, In, Path, ...)
+	GetPosition() *ASTPosition // Byte offsets of the node in the expression source
 }
 
 // ASTPosition represents the position of an AST node
 type ASTPosition struct {
-	Start int
-	End   int
+	Start int `json:"start"`
+	End   int `json:"end"`
 }
 
-// BinaryNode represents a binary operation (&&, ||, ==, !=, etc.)
+// BinaryNode represents a binary operation (&&, ||, ==, !=, +, -, etc.)
 type BinaryNode struct {
 	Operator string
 	Left     ASTNode
@@ -123,8 +158,8 @@ type BinaryNode struct {
 	Position ASTPosition
 }
 
-func (n *BinaryNode) nodeType() string        { return "Binary" }
-func (n *BinaryNode) getPosition() *ASTPosition { return &n.Position }
+func (n *BinaryNode) NodeType() string          { return "Binary" }
+func (n *BinaryNode) GetPosition() *ASTPosition { return &n.Position }
 
 // UnaryNode represents a unary operation (!)
 type UnaryNode struct {
@@ -133,8 +168,8 @@ type UnaryNode struct {
 	Position ASTPosition
 }
 
-func (n *UnaryNode) nodeType() string        { return "Unary" }
-func (n *UnaryNode) getPosition() *ASTPosition { return &n.Position }
+func (n *UnaryNode) NodeType() string          { return "Unary" }
+func (n *UnaryNode) GetPosition() *ASTPosition { return &n.Position }
 
 // InNode represents an 'in' or 'not in' operation
 type InNode struct {
@@ -144,8 +179,17 @@ type InNode struct {
 	Position ASTPosition
 }
 
-func (n *InNode) nodeType() string        { return "In" }
-func (n *InNode) getPosition() *ASTPosition { return &n.Position }
+func (n *InNode) NodeType() string          { return "In" }
+func (n *InNode) GetPosition() *ASTPosition { return &n.Position }
+
+// ArrayNode represents an array literal: [a, 'b', .field]
+type ArrayNode struct {
+	Elements []ASTNode
+	Position ASTPosition
+}
+
+func (n *ArrayNode) NodeType() string          { return "Array" }
+func (n *ArrayNode) GetPosition() *ASTPosition { return &n.Position }
 
 // PathNode represents a path reference
 type PathNode struct {
@@ -155,8 +199,18 @@ type PathNode struct {
 	Position ASTPosition
 }
 
-func (n *PathNode) nodeType() string        { return "Path" }
-func (n *PathNode) getPosition() *ASTPosition { return &n.Position }
+func (n *PathNode) NodeType() string          { return "Path" }
+func (n *PathNode) GetPosition() *ASTPosition { return &n.Position }
+
+// VariableNode represents a reference to an environment variable ($mode, $user.roles)
+type VariableNode struct {
+	Name     string
+	Segments []PathSegment // identifier and index segments after the name
+	Position ASTPosition
+}
+
+func (n *VariableNode) NodeType() string          { return "Variable" }
+func (n *VariableNode) GetPosition() *ASTPosition { return &n.Position }
 
 // PathSegment represents a segment of a path
 type PathSegment struct {
@@ -166,13 +220,13 @@ type PathSegment struct {
 
 // LiteralNode represents a literal value
 type LiteralNode struct {
-	ValueType string // "string", "number", "boolean", "null"
+	ValueType string // "string", "number", "boolean", "null", "date", "duration"
 	Value     interface{}
 	Position  ASTPosition
 }
 
-func (n *LiteralNode) nodeType() string        { return "Literal" }
-func (n *LiteralNode) getPosition() *ASTPosition { return &n.Position }
+func (n *LiteralNode) NodeType() string          { return "Literal" }
+func (n *LiteralNode) GetPosition() *ASTPosition { return &n.Position }
 
 // GroupNode represents a parenthesized expression
 type GroupNode struct {
@@ -180,8 +234,8 @@ type GroupNode struct {
 	Position   ASTPosition
 }
 
-func (n *GroupNode) nodeType() string          { return "Group" }
-func (n *GroupNode) getPosition() *ASTPosition { return &n.Position }
+func (n *GroupNode) NodeType() string          { return "Group" }
+func (n *GroupNode) GetPosition() *ASTPosition { return &n.Position }
 
 // TernaryNode represents a ternary expression (condition ? trueValue : falseValue)
 type TernaryNode struct {
@@ -191,5 +245,15 @@ type TernaryNode struct {
 	Position   ASTPosition
 }
 
-func (n *TernaryNode) nodeType() string          { return "Ternary" }
-func (n *TernaryNode) getPosition() *ASTPosition { return &n.Position }
+func (n *TernaryNode) NodeType() string          { return "Ternary" }
+func (n *TernaryNode) GetPosition() *ASTPosition { return &n.Position }
+
+// QuantifierNode represents an explicit wildcard quantifier (any(...), all(...), none(...))
+type QuantifierNode struct {
+	Quantifier string // "any", "all", "none"
+	Expression ASTNode
+	Position   ASTPosition
+}
+
+func (n *QuantifierNode) NodeType() string          { return "Quantifier" }
+func (n *QuantifierNode) GetPosition() *ASTPosition { return &n.Position }
@@ -3,26 +3,124 @@ package validator
 import (
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
-// Validator is the main validator struct
+// Validator is the main validator struct.
+// A Validator is safe for concurrent use. Its configuration is an immutable snapshot:
+// AddRule, SetTraceConditions and SetConditionLimits build a new snapshot and swap it
+// in, so a validation in progress keeps the configuration it started with.
 type Validator struct {
+	mu     sync.Mutex // Serializes configuration changes
+	config atomic.Pointer[config]
+}
+
+// config is the configuration of a validator at one point in time.
+// It is never modified once a Validator uses it.
+type config struct {
 	spec            Spec
 	rules           map[string]RuleFunc
+	limits          Limits
 	conditionParser *ConditionParser
+	plan            *plan
+	traceConditions bool
+	parallelism     int
+	pathFormat      PathFormat
 }
 
-// NewValidator creates a new validator instance
-func NewValidator(spec Spec) *Validator {
-	return &Validator{
-		spec:            spec,
-		rules:           DefaultRules(),
-		conditionParser: NewConditionParser(),
+// Option configures a Validator created by NewValidator
+type Option func(*config)
+
+// WithRule registers a validation rule, replacing a built-in rule of the same name
+func WithRule(name string, fn RuleFunc) Option {
+	return func(c *config) {
+		c.rules[name] = fn
+	}
+}
+
+// WithRules registers several validation rules
+func WithRules(rules map[string]RuleFunc) Option {
+	return func(c *config) {
+		for name, fn := range rules {
+			c.rules[name] = fn
+		}
+	}
+}
+
+// WithConditionLimits sets the limits enforced on the spec's condition expressions
+// (DefaultLimits unless set). An expression that exceeds a limit is treated like one
+// that does not parse; use Spec.CheckWithOptions to report them. A condition whose
+// evaluation exceeds MaxExpansions, which depends on the data, fails its field with
+// the rule "condition", so that data too large to check never passes unchecked.
+func WithConditionLimits(limits Limits) Option {
+	return func(c *config) {
+		c.limits = limits
+	}
+}
+
+// WithTraceConditions enables attaching an evaluation trace to errors whose rule
+// depends on a condition: conditional required failures and rules with ternary values.
+// Traces are only computed for failing fields, so valid data costs nothing extra.
+func WithTraceConditions(enabled bool) Option {
+	return func(c *config) {
+		c.traceConditions = enabled
+	}
+}
+
+// WithParallelism validates the items of large repeatable groups, such as the rows
+// of a bulk import, on up to workers goroutines. Errors are reported in the same
+// order as without it. Below 2 (the default), items are validated sequentially;
+// runtime.GOMAXPROCS(0) is a good value for CPU-bound validation.
+func WithParallelism(workers int) Option {
+	return func(c *config) {
+		c.parallelism = workers
+	}
+}
+
+// WithPathFormat sets the notation of the Field paths of validation errors: DotPath
+// (items.0.name, the default), BracketPath (items[0][name]) to match form input
+// names, or JSONPointerPath (/items/0/name) for JSON APIs
+func WithPathFormat(format PathFormat) Option {
+	return func(c *config) {
+		c.pathFormat = format
 	}
 }
 
+// NewValidator creates a new validator instance
+// The spec is compiled up front into a validation plan: rules are resolved, their
+// parameters parsed and condition expressions and patterns compiled, so that
+// validation never repeats that work.
+func NewValidator(spec Spec, options ...Option) *Validator {
+	c := &config{
+		spec:   spec,
+		rules:  DefaultRules(),
+		limits: DefaultLimits(),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	c.compileConditionParser()
+	c.plan = c.compilePlan()
+
+	v := &Validator{}
+	v.config.Store(c)
+	return v
+}
+
 // Validate validates all data against the spec
 func (v *Validator) Validate(data map[string]interface{}) *ValidationResult {
+	return v.ValidateWithEnv(data, nil)
+}
+
+// ValidateWithEnv validates data against the spec, resolving $ variables in
+// conditions (such as $mode or $user.roles) from env
+func (v *Validator) ValidateWithEnv(data map[string]interface{}, env Env) *ValidationResult {
+	return v.config.Load().validate(data, env)
+}
+
+// validate validates data with the configuration
+func (c *config) validate(data map[string]interface{}, env Env) *ValidationResult {
 	result := &ValidationResult{
 		IsValid: true,
 		Errors:  []ValidationError{},
@@ -30,104 +128,88 @@ func (v *Validator) Validate(data map[string]interface{}) *ValidationResult {
 
 	// Validate all fields defined in spec
 	// Pass data twice: once as current scope data, once as root form data
-	v.validateFields(v.spec.Fields, data, data, []string{}, result)
+	c.validateFields(c.plan.fields, data, data, []string{}, env, c.parallelism, result)
 
 	return result
 }
 
-// ValidateField validates a single field
+// ValidateField validates a single field. The path may be written in any format
+// StringToPath reads, such as items.0.name, items[0][name] or /items/0/name.
 func (v *Validator) ValidateField(path string, value interface{}, allData map[string]interface{}) *string {
+	c := v.config.Load()
 	pathParts := StringToPath(path)
 
 	// Find the field definition
-	field := v.findFieldByPath(pathParts)
-	if field == nil {
+	fp := c.plan.find(pathParts)
+	if fp == nil {
 		return nil // No field definition found, skip validation
 	}
 
-	ctx := &ValidationContext{
-		CurrentPath: pathParts,
-		FormData:    allData,
-		FieldDef:    field,
-	}
-
-	// Check required
-	if isRequired, condition := v.isFieldRequired(field, allData, pathParts); isRequired {
-		if isEmpty(value) {
-			msg := v.getErrorMessage(field, "required", "This field is required")
-			return &msg
-		}
-	} else if condition != "" {
-		// Conditional required that evaluated to false - skip if empty
-		if isEmpty(value) {
-			return nil
-		}
+	if errs := c.checkField(fp, value, allData, pathParts, nil, true); len(errs) > 0 {
+		return &errs[0].Message
 	}
+	return nil
+}
 
-	// Skip other validations if empty and not required
-	if isEmpty(value) {
-		return nil
-	}
+// AddRule adds a custom validation rule.
+// It is safe to call while other goroutines validate; validations that already
+// started do not see the new rule. Prefer WithRule when creating the validator.
+func (v *Validator) AddRule(name string, fn RuleFunc) {
+	v.update(func(c *config) {
+		c.rules[name] = fn
+		c.plan = c.compilePlan()
+	})
+}
 
-	// For number type fields, implicitly run number validation first
-	// if there's no explicit number rule (to catch invalid numbers before min/max)
-	if field.Type == "number" {
-		hasExplicitNumberRule := false
-		if field.Rules != nil {
-			_, hasExplicitNumberRule = field.Rules["number"]
-		}
-		if !hasExplicitNumberRule {
-			numberRule := v.rules["number"]
-			if numberRule != nil {
-				errMsg := numberRule(value, nil, allData, ctx)
-				if errMsg != nil {
-					customMsg := v.getErrorMessage(field, "number", *errMsg)
-					return &customMsg
-				}
-			}
-		}
-	}
+// SetTraceConditions enables or disables condition traces, like WithTraceConditions
+func (v *Validator) SetTraceConditions(enabled bool) {
+	v.update(func(c *config) {
+		c.traceConditions = enabled
+	})
+}
 
-	// Run all field rules
-	if field.Rules != nil {
-		for ruleName, ruleValue := range field.Rules {
-			if ruleName == "required" {
-				continue // Already handled above
-			}
+// SetConditionLimits replaces the condition limits, like WithConditionLimits, and
+// recompiles the spec's conditions
+func (v *Validator) SetConditionLimits(limits Limits) {
+	v.update(func(c *config) {
+		c.limits = limits
+		c.compileConditionParser()
+		c.plan = c.compilePlan()
+	})
+}
 
-			errMsg := v.applyRule(ruleName, ruleValue, value, allData, ctx)
-			if errMsg != nil {
-				customMsg := v.getErrorMessage(field, ruleName, *errMsg)
-				return &customMsg
-			}
-		}
-	}
+// update applies a change to a copy of the configuration and swaps it in
+func (v *Validator) update(change func(*config)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 
-	return nil
+	c := *v.config.Load()
+	c.rules = cloneRules(c.rules)
+	change(&c)
+	v.config.Store(&c)
 }
 
-// AddRule adds a custom validation rule
-func (v *Validator) AddRule(name string, fn RuleFunc) {
-	v.rules[name] = fn
+// compileConditionParser creates the condition parser for the spec and limits and
+// compiles the spec's conditions into it
+func (c *config) compileConditionParser() {
+	c.conditionParser = NewSpecConditionParser(c.spec, c.limits)
+	c.compileConditions(c.spec.Fields)
 }
 
 // validateFields recursively validates fields
 // data: current scope data for value access
 // rootData: full form data for condition evaluation
-func (v *Validator) validateFields(fields []Field, data map[string]interface{}, rootData map[string]interface{}, currentPath []string, result *ValidationResult) {
-	for _, field := range fields {
-		fieldPath := AppendToPath(currentPath, field.Name)
-		value := v.getValueFromData(data, field.Name)
-
-		// Handle repeatable/multiple groups
+// workers: goroutines available for the items of repeatable groups
+func (c *config) validateFields(fields []*fieldPlan, data map[string]interface{}, rootData map[string]interface{}, currentPath []string, env Env, workers int, result *ValidationResult) {
+	for _, fp := range fields {
+		field := fp.field
+		fieldPath := fp.pathIn(currentPath)
+		value := c.getValueFromData(data, field.Name)
+
+		// Handle repeatable/multiple groups, given as a list or keyed by unique keys
 		if field.Multiple && field.Fields != nil {
-			if arr, ok := value.([]interface{}); ok {
-				for i, item := range arr {
-					if itemMap, ok := item.(map[string]interface{}); ok {
-						itemPath := AppendToPath(fieldPath, strconv.Itoa(i))
-						v.validateFields(field.Fields, itemMap, rootData, itemPath, result)
-					}
-				}
+			if items, segments, ok := repeatableItems(value); ok {
+				c.validateItems(fp, items, segments, rootData, fieldPath, env, workers, result)
 			}
 			continue
 		}
@@ -136,7 +218,7 @@ func (v *Validator) validateFields(fields []Field, data map[string]interface{},
 		if field.MultipleOnly && field.Fields != nil {
 			if objData, ok := value.(map[string]interface{}); ok {
 				// Validate as a regular nested group (no array index in path)
-				v.validateFields(field.Fields, objData, rootData, fieldPath, result)
+				c.validateFields(fp.children, objData, rootData, fieldPath, env, workers, result)
 			}
 			continue
 		}
@@ -144,178 +226,249 @@ func (v *Validator) validateFields(fields []Field, data map[string]interface{},
 		// Handle nested groups
 		if field.Fields != nil && len(field.Fields) > 0 {
 			if nestedData, ok := value.(map[string]interface{}); ok {
-				v.validateFields(field.Fields, nestedData, rootData, fieldPath, result)
+				c.validateFields(fp.children, nestedData, rootData, fieldPath, env, workers, result)
 			}
 			continue
 		}
 
+		// The values of a repeatable field keyed by unique keys are checked as a list
+		if field.Multiple {
+			if items, _, ok := repeatableItems(value); ok {
+				value = items
+			}
+		}
+
 		// Validate the field - use rootData for condition evaluation
-		v.validateSingleField(&field, value, rootData, fieldPath, result)
+		if errs := c.checkField(fp, value, rootData, fieldPath, env, false); len(errs) > 0 {
+			result.IsValid = false
+			result.Errors = append(result.Errors, errs...)
+		}
 	}
 }
 
-// validateSingleField validates a single field and adds errors to result
-func (v *Validator) validateSingleField(field *Field, value interface{}, allData map[string]interface{}, fieldPath []string, result *ValidationResult) {
-	ctx := &ValidationContext{
-		CurrentPath: fieldPath,
-		FormData:    allData,
-		FieldDef:    field,
+// minParallelItems is the number of items from which a repeatable group is validated
+// in parallel; below it, starting goroutines costs more than it saves
+const minParallelItems = 64
+
+// validateItems validates the items of a repeatable group. With more than one worker
+// and enough items, the items are shared out among a bounded pool of goroutines,
+// each validating an item into its own result; the results are merged by index, so
+// errors come out in the same order as sequential validation. Groups nested in the
+// items are validated sequentially, which keeps the number of goroutines bounded.
+// segments holds the path segment of each item, its index or unique key.
+func (c *config) validateItems(fp *fieldPlan, items []interface{}, segments []string, rootData map[string]interface{}, fieldPath []string, env Env, workers int, result *ValidationResult) {
+	validateItem := func(i int, nestedWorkers int, into *ValidationResult) {
+		if itemMap, ok := items[i].(map[string]interface{}); ok {
+			itemPath := AppendToPath(fieldPath, segments[i])
+			c.validateFields(fp.children, itemMap, rootData, itemPath, env, nestedWorkers, into)
+		}
+	}
+
+	if workers < 2 || len(items) < minParallelItems {
+		for i := range items {
+			validateItem(i, workers, result)
+		}
+		return
 	}
 
-	pathStr := PathToString(fieldPath)
+	if workers > len(items) {
+		workers = len(items)
+	}
+	results := make([]ValidationResult, len(items))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(items) {
+					return
+				}
+				validateItem(i, 1, &results[i])
+			}
+		}()
+	}
+	wg.Wait()
 
-	// Check required
-	if isRequired, _ := v.isFieldRequired(field, allData, fieldPath); isRequired {
-		if isEmpty(value) {
+	for i := range results {
+		if len(results[i].Errors) > 0 {
 			result.IsValid = false
-			result.Errors = append(result.Errors, ValidationError{
-				Field:   pathStr,
+			result.Errors = append(result.Errors, results[i].Errors...)
+		}
+	}
+}
+
+// checkField validates the value of a field and returns its errors. A required field
+// that is empty only reports required; with stopAtFirst, checking ends at the
+// first failing rule.
+func (c *config) checkField(fp *fieldPlan, value interface{}, allData map[string]interface{}, fieldPath []string, env Env, stopAtFirst bool) []ValidationError {
+	field := fp.field
+
+	// Check required
+	if isEmpty(value) {
+		required, err := fp.isRequired(allData, fieldPath, env)
+		if err != nil {
+			return []ValidationError{c.conditionError(field, fieldPath, value, err)}
+		}
+		if required {
+			return []ValidationError{{
+				Field:   c.pathFormat.Format(fieldPath),
 				Rule:    "required",
-				Message: v.getErrorMessage(field, "required", "This field is required"),
+				Message: c.getErrorMessage(field, "required", "This field is required"),
 				Value:   value,
-			})
-			return // Don't check other rules if required fails
+				Trace:   c.traceRequired(field, allData, fieldPath, env),
+			}}
 		}
+		// Skip other validations if empty
+		return nil
 	}
 
-	// Skip other validations if empty
-	if isEmpty(value) {
-		return
+	ctx := &ValidationContext{
+		CurrentPath: fieldPath,
+		FormData:    allData,
+		FieldDef:    field,
+		Env:         env,
+		patterns:    c.plan.patterns,
 	}
 
 	// For number type fields, implicitly run number validation first
 	// if there's no explicit number rule (to catch invalid numbers before min/max)
-	if field.Type == "number" {
-		hasExplicitNumberRule := false
-		if field.Rules != nil {
-			_, hasExplicitNumberRule = field.Rules["number"]
-		}
-		if !hasExplicitNumberRule {
-			numberRule := v.rules["number"]
-			if numberRule != nil {
-				errMsg := numberRule(value, nil, allData, ctx)
-				if errMsg != nil {
-					result.IsValid = false
-					result.Errors = append(result.Errors, ValidationError{
-						Field:   pathStr,
-						Rule:    "number",
-						Message: v.getErrorMessage(field, "number", *errMsg),
-						Value:   value,
-					})
-					return // Stop at first error
-				}
-			}
+	if fp.number != nil {
+		if errMsg := fp.number(value, nil, allData, ctx); errMsg != nil {
+			return []ValidationError{{
+				Field:   c.pathFormat.Format(fieldPath),
+				Rule:    "number",
+				Message: c.getErrorMessage(field, "number", *errMsg),
+				Value:   value,
+			}}
 		}
 	}
 
 	// Run all field rules
-	if field.Rules != nil {
-		for ruleName, ruleValue := range field.Rules {
-			if ruleName == "required" {
-				continue // Already handled above
-			}
-
-			errMsg := v.applyRule(ruleName, ruleValue, value, allData, ctx)
-			if errMsg != nil {
-				result.IsValid = false
-				result.Errors = append(result.Errors, ValidationError{
-					Field:   pathStr,
-					Rule:    ruleName,
-					Message: v.getErrorMessage(field, ruleName, *errMsg),
-					Value:   value,
-				})
-			}
+	var errs []ValidationError
+	for _, rule := range fp.rules {
+		errMsg, err := c.applyRule(rule, value, allData, ctx)
+		switch {
+		case err != nil:
+			errs = append(errs, c.conditionError(field, fieldPath, value, err))
+		case errMsg != nil:
+			errs = append(errs, ValidationError{
+				Field:   c.pathFormat.Format(fieldPath),
+				Rule:    rule.name,
+				Message: c.getErrorMessage(field, rule.name, *errMsg),
+				Value:   value,
+				Trace:   c.traceRuleValue(rule.value, allData, fieldPath, env),
+			})
+		default:
+			continue
+		}
+		if stopAtFirst {
+			break
 		}
 	}
+	return errs
 }
 
-// isFieldRequired checks if a field is required (handles conditional required)
-func (v *Validator) isFieldRequired(field *Field, allData map[string]interface{}, currentPath []string) (bool, string) {
-	if field.Required == nil {
-		// Check in rules
-		if field.Rules != nil {
-			if reqVal, ok := field.Rules["required"]; ok {
-				return v.evaluateRequired(reqVal, allData, currentPath)
-			}
-		}
-		return false, ""
+// conditionRule is the rule of the errors of conditions that could not be evaluated
+const conditionRule = "condition"
+
+// conditionError reports a condition of a field that could not be evaluated, such as
+// one whose wildcards visit more items than MaxExpansions allows
+func (c *config) conditionError(field *Field, fieldPath []string, value interface{}, err error) ValidationError {
+	return ValidationError{
+		Field:   c.pathFormat.Format(fieldPath),
+		Rule:    conditionRule,
+		Message: c.getErrorMessage(field, conditionRule, "This field could not be validated: "+err.Error()),
+		Value:   value,
+	}
+}
+
+// traceRequired explains the condition that made a field required.
+// Returns nil unless tracing is enabled and the field has a conditional required.
+func (c *config) traceRequired(field *Field, allData map[string]interface{}, currentPath []string, env Env) *TraceNode {
+	reqValue := field.Required
+	if reqValue == nil && field.Rules != nil {
+		reqValue = field.Rules["required"]
+	}
+	condition, ok := reqValue.(string)
+	if !ok || !isConditionExpression(condition) || condition == "true" {
+		return nil
 	}
+	return c.explain(condition, allData, currentPath, env)
+}
 
-	return v.evaluateRequired(field.Required, allData, currentPath)
+// traceRuleValue explains the ternary expression a rule value was resolved from.
+// Returns nil unless tracing is enabled and the rule value is a ternary.
+func (c *config) traceRuleValue(ruleValue interface{}, allData map[string]interface{}, currentPath []string, env Env) *TraceNode {
+	strVal, ok := ruleValue.(string)
+	if !ok || !isTernaryCandidate(strVal) {
+		return nil
+	}
+	return c.explain(strVal, allData, currentPath, env)
 }
 
-// evaluateRequired evaluates a required rule value
-func (v *Validator) evaluateRequired(reqValue interface{}, allData map[string]interface{}, currentPath []string) (bool, string) {
-	switch req := reqValue.(type) {
-	case bool:
-		return req, ""
-	case string:
-		// Conditional required expression
-		if req == "" || req == "false" {
-			return false, req
-		}
-		if req == "true" {
-			return true, ""
-		}
-		// Parse and evaluate condition
-		result, err := v.conditionParser.Evaluate(req, allData, currentPath)
-		if err != nil {
-			return false, req
-		}
-		return result, req
-	default:
-		return false, ""
+// explain evaluates an expression with tracing when tracing is enabled
+func (c *config) explain(expression string, allData map[string]interface{}, currentPath []string, env Env) *TraceNode {
+	if !c.traceConditions {
+		return nil
+	}
+	expr, err := c.conditionParser.Compile(expression)
+	if err != nil {
+		return nil
 	}
+	return expr.ExplainEnv(allData, currentPath, env)
 }
 
-// applyRule applies a validation rule
-func (v *Validator) applyRule(ruleName string, ruleValue interface{}, value interface{}, allData map[string]interface{}, ctx *ValidationContext) *string {
-	// Get the rule function
-	ruleFn, ok := v.rules[ruleName]
-	if !ok {
-		// Check if it's a custom rule in spec
-		if customRule, ok := v.spec.Rules[ruleName]; ok {
-			return v.applyCustomRule(&customRule, value, allData, ctx)
-		}
-		return nil // Unknown rule, skip
+// applyRule applies a compiled validation rule. It returns the error of a ternary
+// rule value whose evaluation exceeded a limit.
+func (c *config) applyRule(rule *rulePlan, value interface{}, allData map[string]interface{}, ctx *ValidationContext) (*string, error) {
+	if rule.custom != nil {
+		return c.applyCustomRule(rule.custom, value, allData, ctx), nil
 	}
 
 	// Handle conditional rule values (ternary expressions)
 	// For numeric rules like min/max, evaluate ternary expressions
-	resolvedValue := v.resolveRuleValue(ruleValue, allData, ctx.CurrentPath)
-
-	// Parse parameters from resolved rule value
-	params := v.parseRuleParams(resolvedValue)
+	params := rule.params
+	if rule.expr != nil {
+		resolved, err := rule.expr.EvalEnv(allData, ctx.CurrentPath, ctx.Env)
+		if err != nil {
+			return nil, err
+		}
+		params = parseRuleParams(resolved)
+	}
 
-	return ruleFn(value, params, allData, ctx)
+	return rule.fn(value, params, allData, ctx), nil
 }
 
-// resolveRuleValue evaluates conditional expressions in rule values
-func (v *Validator) resolveRuleValue(ruleValue interface{}, allData map[string]interface{}, currentPath []string) interface{} {
-	strVal, ok := ruleValue.(string)
-	if !ok {
-		return ruleValue
-	}
+// isTernaryCandidate checks if a rule value string contains a ternary operator (? and :)
+func isTernaryCandidate(value string) bool {
+	return strings.Contains(value, "?") && strings.Contains(value, ":")
+}
 
-	// Check if string contains ternary operator (? and :)
-	if !strings.Contains(strVal, "?") || !strings.Contains(strVal, ":") {
-		return ruleValue
-	}
+// isConditionExpression checks if a required value is a condition rather than a constant
+func isConditionExpression(value string) bool {
+	return value != "" && value != "true" && value != "false"
+}
 
-	// Try to evaluate as a ternary expression
-	result, err := v.conditionParser.EvaluateValue(strVal, allData, currentPath)
-	if err != nil {
-		return ruleValue // Return original if evaluation fails
+// compileConditions compiles every condition expression in the field tree into the
+// parser cache. Parse failures are cached too, so that a malformed expression is not
+// parsed again on every validation; ConditionParser.Compile returns its error.
+func (c *config) compileConditions(fields []Field) {
+	for i := range fields {
+		for _, fe := range fieldExpressions(&fields[i]) {
+			c.conditionParser.Compile(fe.expression)
+		}
+		c.compileConditions(fields[i].Fields)
 	}
-
-	return result
 }
 
 // applyCustomRule applies a custom rule from spec
-func (v *Validator) applyCustomRule(rule *Rule, value interface{}, allData map[string]interface{}, ctx *ValidationContext) *string {
+func (c *config) applyCustomRule(rule *Rule, value interface{}, allData map[string]interface{}, ctx *ValidationContext) *string {
 	// Pattern matching
 	if rule.Pattern != "" {
-		matchRule := v.rules["match"]
+		matchRule := c.rules["match"]
 		if matchRule != nil {
 			return matchRule(value, []string{rule.Pattern}, allData, ctx)
 		}
@@ -323,7 +476,7 @@ func (v *Validator) applyCustomRule(rule *Rule, value interface{}, allData map[s
 
 	// Min value
 	if rule.Min != nil {
-		minRule := v.rules["min"]
+		minRule := c.rules["min"]
 		if minRule != nil {
 			errMsg := minRule(value, []string{strconv.Itoa(*rule.Min)}, allData, ctx)
 			if errMsg != nil {
@@ -337,7 +490,7 @@ func (v *Validator) applyCustomRule(rule *Rule, value interface{}, allData map[s
 
 	// Max value
 	if rule.Max != nil {
-		maxRule := v.rules["max"]
+		maxRule := c.rules["max"]
 		if maxRule != nil {
 			errMsg := maxRule(value, []string{strconv.Itoa(*rule.Max)}, allData, ctx)
 			if errMsg != nil {
@@ -353,7 +506,7 @@ func (v *Validator) applyCustomRule(rule *Rule, value interface{}, allData map[s
 }
 
 // parseRuleParams parses parameters from a rule value
-func (v *Validator) parseRuleParams(ruleValue interface{}) []string {
+func parseRuleParams(ruleValue interface{}) []string {
 	switch val := ruleValue.(type) {
 	case bool:
 		return nil
@@ -387,7 +540,7 @@ func (v *Validator) parseRuleParams(ruleValue interface{}) []string {
 }
 
 // getErrorMessage gets the error message for a rule
-func (v *Validator) getErrorMessage(field *Field, ruleName string, defaultMsg string) string {
+func (c *config) getErrorMessage(field *Field, ruleName string, defaultMsg string) string {
 	if field.Messages != nil {
 		if msg, ok := field.Messages[ruleName]; ok {
 			return msg
@@ -397,61 +550,24 @@ func (v *Validator) getErrorMessage(field *Field, ruleName string, defaultMsg st
 }
 
 // getValueFromData retrieves a value from data by field name
-func (v *Validator) getValueFromData(data map[string]interface{}, fieldName string) interface{} {
+func (c *config) getValueFromData(data map[string]interface{}, fieldName string) interface{} {
 	if data == nil {
 		return nil
 	}
 	return data[fieldName]
 }
 
-// findFieldByPath finds a field definition by path
-func (v *Validator) findFieldByPath(path []string) *Field {
-	if len(path) == 0 {
-		return nil
-	}
-
-	return v.findFieldInList(v.spec.Fields, path, 0)
-}
-
-// findFieldInList recursively finds a field in a list
-func (v *Validator) findFieldInList(fields []Field, path []string, depth int) *Field {
-	if depth >= len(path) {
-		return nil
-	}
-
-	targetName := path[depth]
-
-	// Skip numeric indices (array elements)
-	if _, err := strconv.Atoi(targetName); err == nil {
-		if depth+1 < len(path) {
-			// Continue searching in the same fields (for repeatable groups)
-			return v.findFieldInList(fields, path, depth+1)
-		}
-		return nil
-	}
-
-	for i := range fields {
-		field := &fields[i]
-		if field.Name == targetName {
-			if depth == len(path)-1 {
-				return field
-			}
-			if field.Fields != nil {
-				return v.findFieldInList(field.Fields, path, depth+1)
-			}
-		}
-	}
-
-	return nil
-}
-
 // Helper function to get nested value from data
 func getNestedValue(data map[string]interface{}, path []string) interface{} {
 	if len(path) == 0 {
 		return data
 	}
+	return lookupValue(data, path)
+}
 
-	var current interface{} = data
+// lookupValue follows path through nested maps and arrays, returning nil when a
+// segment does not exist
+func lookupValue(current interface{}, path []string) interface{} {
 	for _, segment := range path {
 		if current == nil {
 			return nil
@@ -474,12 +590,41 @@ func getNestedValue(data map[string]interface{}, path []string) interface{} {
 	return current
 }
 
+// fieldTypeAt returns the type of the field at a data path, skipping item indices,
+// unique keys and wildcards of repeatable groups, or an empty string if no field matches
+func (s Spec) fieldTypeAt(path []string) string {
+	fields := s.Fields
+	var field *Field
+	for _, segment := range path {
+		if IsItemSegment(segment) || segment == "*" {
+			continue
+		}
+		if field = findField(fields, segment); field == nil {
+			return ""
+		}
+		fields = field.Fields
+	}
+	if field == nil {
+		return ""
+	}
+	return field.Type
+}
+
 // GetSpec returns the spec
 func (v *Validator) GetSpec() Spec {
-	return v.spec
+	return v.config.Load().spec
 }
 
-// GetRules returns the registered rules
+// GetRules returns a copy of the registered rules
 func (v *Validator) GetRules() map[string]RuleFunc {
-	return v.rules
+	return cloneRules(v.config.Load().rules)
+}
+
+// cloneRules copies a rule map
+func cloneRules(rules map[string]RuleFunc) map[string]RuleFunc {
+	clone := make(map[string]RuleFunc, len(rules))
+	for name, fn := range rules {
+		clone[name] = fn
+	}
+	return clone
 }
@@ -5,7 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // TestCase represents a single test case from the JSON files
@@ -614,6 +619,731 @@ func TestUnique(t *testing.T) {
 	}
 }
 
+// TestValidationPlan tests that the compiled plan follows rule changes and reports
+// the rules of a field in a stable order
+func TestValidationPlan(t *testing.T) {
+	spec := Spec{
+		Fields: []Field{
+			{Name: "mode", Type: "text"},
+			{Name: "code", Type: "text", Rules: map[string]interface{}{
+				"minlength": 5,
+				"match":     "^[0-9]+$",
+				"maxlength": ".mode == 'short' ? 2 : 10",
+				"even":      true,
+			}},
+			{Name: "items", Type: "group", Multiple: true, Fields: []Field{
+				{Name: "qty", Type: "number", Rules: map[string]interface{}{"required": "...mode == 'order'"}},
+			}},
+		},
+	}
+	v := NewValidator(spec)
+	data := map[string]interface{}{
+		"mode":  "short",
+		"code":  "abc",
+		"items": []interface{}{map[string]interface{}{"qty": "x"}, map[string]interface{}{}},
+	}
+
+	var got []string
+	for _, err := range v.Validate(data).Errors {
+		got = append(got, err.Field+":"+err.Rule)
+	}
+	want := []string{"code:match", "code:maxlength", "code:minlength", "items.0.qty:number"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Errors = %v, want %v", got, want)
+	}
+
+	// Rules added after creation are compiled into the plan
+	v.AddRule("even", func(value interface{}, params []string, allData map[string]interface{}, ctx *ValidationContext) *string {
+		if n, ok := toNumber(value); ok && int(n)%2 == 0 {
+			return nil
+		}
+		msg := "Please enter an even number"
+		return &msg
+	})
+	if msg := v.ValidateField("code", "12345", data); msg == nil || *msg != "Please enter an even number" {
+		t.Errorf("ValidateField = %v, want the even error", msg)
+	}
+	if msg := v.ValidateField("code", "12346", data); msg == nil || *msg != "Please enter no more than 2 characters" {
+		t.Errorf("ValidateField = %v, want the maxlength error", msg)
+	}
+	data["mode"] = "order"
+	if msg := v.ValidateField("code", "12346", data); msg != nil {
+		t.Errorf("ValidateField = %q, want no error", *msg)
+	}
+	if msg := v.ValidateField("items.1.qty", nil, data); msg == nil || *msg != "This field is required" {
+		t.Errorf("ValidateField = %v, want the required error", msg)
+	}
+}
+
+// TestValidatorOptions tests configuring a validator at creation
+func TestValidatorOptions(t *testing.T) {
+	spec := Spec{Fields: []Field{
+		{Name: "code", Type: "text", Required: ".a == 1 || .a == 2", Rules: map[string]interface{}{"upper": true}},
+	}}
+	upper := func(value interface{}, params []string, allData map[string]interface{}, ctx *ValidationContext) *string {
+		if s := toString(value); s != strings.ToUpper(s) {
+			msg := "Please use upper case"
+			return &msg
+		}
+		return nil
+	}
+	v := NewValidator(spec, WithRule("upper", upper), WithTraceConditions(true), WithConditionLimits(Limits{MaxLength: 10}))
+
+	result := v.Validate(map[string]interface{}{"code": "abc"})
+	if len(result.Errors) != 1 || result.Errors[0].Rule != "upper" {
+		t.Errorf("Expected the upper rule to fail, got %v", result.Errors)
+	}
+	// The required condition exceeds MaxLength, so an empty value cannot be validated
+	if result := v.Validate(map[string]interface{}{"a": 1}); len(result.Errors) != 1 || result.Errors[0].Rule != conditionRule {
+		t.Errorf("Expected a condition over the limits to be reported, got %v", result.Errors)
+	}
+
+	v = NewValidator(spec, WithRules(map[string]RuleFunc{"upper": upper}), WithTraceConditions(true))
+	result = v.Validate(map[string]interface{}{"a": 2})
+	if len(result.Errors) != 1 || result.Errors[0].Trace == nil {
+		t.Errorf("Expected a traced required error, got %v", result.Errors)
+	}
+
+	rules := v.GetRules()
+	delete(rules, "upper")
+	if _, ok := v.GetRules()["upper"]; !ok {
+		t.Error("Expected GetRules to return a copy")
+	}
+}
+
+// TestUnparsableRequired tests that a required condition that does not parse fails
+// empty values with a condition error rather than making the field optional
+func TestUnparsableRequired(t *testing.T) {
+	v := NewValidator(Spec{Fields: []Field{
+		{Name: "status", Type: "text"},
+		{Name: "note", Type: "text", Required: ".status == in-progress"},
+		{Name: "memo", Type: "text", Rules: map[string]interface{}{"required": ".status ==", "maxlength": 3}},
+	}})
+
+	result := v.Validate(map[string]interface{}{"status": "in-progress"})
+	var got []string
+	for _, e := range result.Errors {
+		got = append(got, e.Field+":"+e.Rule)
+	}
+	if result.IsValid || strings.Join(got, " ") != "note:condition memo:condition" {
+		t.Errorf("Expected condition errors for note and memo, got %v", result.Errors)
+	}
+	if len(result.Errors) > 0 && !strings.Contains(result.Errors[0].Message, "cannot parse the required condition") {
+		t.Errorf("Expected the parse error in the message, got %q", result.Errors[0].Message)
+	}
+
+	// The condition only decides whether an empty value is allowed
+	result = v.Validate(map[string]interface{}{"note": "x", "memo": "long"})
+	if len(result.Errors) != 1 || result.Errors[0].Field != "memo" || result.Errors[0].Rule != "maxlength" {
+		t.Errorf("Expected only memo:maxlength, got %v", result.Errors)
+	}
+}
+
+// TestConcurrentRuleRegistration tests changing the configuration of a validator while
+// other goroutines validate with it. Run with -race.
+func TestConcurrentRuleRegistration(t *testing.T) {
+	spec := Spec{Fields: []Field{
+		{Name: "mode", Type: "text"},
+		{Name: "code", Type: "text", Rules: map[string]interface{}{"maxlength": ".mode == 'short' ? 2 : 10", "plugin0": true}},
+		{Name: "items", Type: "group", Multiple: true, Fields: []Field{
+			{Name: "qty", Type: "number", Rules: map[string]interface{}{"required": "...mode == 'order'", "min": 1}},
+		}},
+	}}
+	v := NewValidator(spec)
+	data := map[string]interface{}{
+		"mode":  "order",
+		"code":  "abc",
+		"items": []interface{}{map[string]interface{}{"qty": 0}, map[string]interface{}{}},
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				// plugin0 may or may not be registered yet, but the plan is never half-built
+				result := v.Validate(data)
+				if n := len(result.Errors); n != 2 && n != 3 {
+					t.Errorf("Expected 2 or 3 errors, got %v", result.Errors)
+				}
+				if msg := v.ValidateField("items.1.qty", nil, data); msg == nil {
+					t.Error("Expected items.1.qty to be required")
+				}
+				v.GetRules()
+			}
+		}()
+	}
+
+	fail := func(value interface{}, params []string, allData map[string]interface{}, ctx *ValidationContext) *string {
+		msg := "Rejected by plugin"
+		return &msg
+	}
+	for i := 0; i < 20; i++ {
+		v.AddRule(fmt.Sprintf("plugin%d", i), fail)
+		v.SetTraceConditions(i%2 == 0)
+		v.SetConditionLimits(DefaultLimits())
+	}
+	wg.Wait()
+
+	if result := v.Validate(data); len(result.Errors) != 3 {
+		t.Errorf("Expected the registered rule to apply, got %v", result.Errors)
+	}
+}
+
+// TestParallelValidation tests that parallel validation of repeatable groups reports
+// the same errors in the same order as sequential validation
+func TestParallelValidation(t *testing.T) {
+	spec, data := bulkImport(500)
+	// A repeatable group nested in the parallel items
+	spec.Fields = append(spec.Fields, Field{Name: "batches", Type: "group", Multiple: true, Fields: []Field{
+		{Name: "rows", Type: "group", Multiple: true, Fields: spec.Fields[1].Fields},
+	}})
+	data["batches"] = []interface{}{map[string]interface{}{"rows": data["rows"]}, "not an item", map[string]interface{}{"rows": data["rows"]}}
+
+	want := NewValidator(spec).Validate(data)
+	if len(want.Errors) == 0 {
+		t.Fatal("Expected the payload to have errors")
+	}
+	for _, workers := range []int{2, 7, 1000} {
+		got := NewValidator(spec, WithParallelism(workers)).Validate(data)
+		if got.IsValid != want.IsValid || len(got.Errors) != len(want.Errors) {
+			t.Fatalf("With %d workers got %d errors, want %d", workers, len(got.Errors), len(want.Errors))
+		}
+		for i := range want.Errors {
+			if got.Errors[i].Field != want.Errors[i].Field || got.Errors[i].Rule != want.Errors[i].Rule {
+				t.Fatalf("With %d workers error %d is %s:%s, want %s:%s", workers, i,
+					got.Errors[i].Field, got.Errors[i].Rule, want.Errors[i].Field, want.Errors[i].Rule)
+			}
+		}
+	}
+
+	// Groups below minParallelItems are validated sequentially
+	_, small := bulkImport(minParallelItems - 1)
+	got, expected := NewValidator(spec, WithParallelism(4)).Validate(small), NewValidator(spec).Validate(small)
+	if len(got.Errors) == 0 || len(got.Errors) != len(expected.Errors) {
+		t.Errorf("Expected %d errors, got %d", len(expected.Errors), len(got.Errors))
+	}
+}
+
+// TestValidateStream tests validation of a JSON stream against validation of the decoded data
+func TestValidateStream(t *testing.T) {
+	spec, data := bulkImport(300)
+	spec.Fields = append(spec.Fields, Field{Name: "title", Type: "text", Rules: map[string]interface{}{"required": true}})
+	payload, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewValidator(spec)
+
+	stream := func(input string) ([]ValidationError, bool, error) {
+		var errs []ValidationError
+		valid, err := v.ValidateStream(json.NewDecoder(strings.NewReader(input)), nil, func(e ValidationError) {
+			errs = append(errs, e)
+		})
+		return errs, valid, err
+	}
+
+	want := v.Validate(data)
+	got, valid, err := stream(string(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid || len(got) != len(want.Errors) {
+		t.Fatalf("Expected %d errors, got %d (valid %v)", len(want.Errors), len(got), valid)
+	}
+	for i := range got {
+		if got[i].Field != want.Errors[i].Field || got[i].Rule != want.Errors[i].Rule {
+			t.Fatalf("Error %d is %s:%s, want %s:%s", i, got[i].Field, got[i].Rule, want.Errors[i].Field, want.Errors[i].Rule)
+		}
+	}
+
+	t.Run("conditions see the whole object", func(t *testing.T) {
+		row := `{"sku": "ABC-0001", "qty": 500, "note": "pallet"}`
+		sorted := func(errs []ValidationError) string {
+			var fields []string
+			for _, e := range errs {
+				fields = append(fields, e.Field+":"+e.Rule)
+			}
+			sort.Strings(fields)
+			return strings.Join(fields, " ")
+		}
+		for _, tc := range []struct {
+			name     string
+			field    Field
+			input    string
+			buffered bool
+		}{
+			{"items read a preceding field", Field{}, `{"title": "t", "mode": "wholesale", "rows": [` + row + `]}`, false},
+			{"items read a later field", Field{}, `{"title": "t", "rows": [` + row + `], "mode": "wholesale"}`, false},
+			{"items read an absent field", Field{}, `{"title": "t", "rows": [` + row + `]}`, false},
+			{"a field reads the items", Field{Name: "summary", Type: "text", Rules: map[string]interface{}{"required": "any(rows.*.qty > 50)"}},
+				`{"title": "t", "mode": "wholesale", "rows": [` + row + `]}`, true},
+			{"a field compares with the items", Field{Name: "first_sku", Type: "text", Rules: map[string]interface{}{"equalTo": "rows.0.sku"}},
+				`{"first_sku": "ABC-0002", "rows": [` + row + `], "title": "t"}`, true},
+			{"a rule function may read anything", Field{Name: "code", Type: "text", Rules: map[string]interface{}{"checksum": true}},
+				`{"code": "x", "rows": [` + row + `], "title": "t", "mode": "wholesale"}`, true},
+		} {
+			spec, _ := bulkImport(0)
+			spec.Fields = append(spec.Fields, Field{Name: "title", Type: "text", Rules: map[string]interface{}{"required": true}})
+			if tc.field.Name != "" {
+				spec.Fields = append(spec.Fields, tc.field)
+			}
+			v := NewValidator(spec, WithRule("checksum", func(value interface{}, params []string, allData map[string]interface{}, ctx *ValidationContext) *string {
+				if allData["rows"] == nil {
+					msg := "no rows"
+					return &msg
+				}
+				return nil
+			}))
+			if buffered := v.config.Load().plan.streaming.buffered["rows"]; buffered != tc.buffered {
+				t.Errorf("%s: expected buffered %v, got %v", tc.name, tc.buffered, buffered)
+			}
+
+			var data map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.input), &data); err != nil {
+				t.Fatal(err)
+			}
+			var got []ValidationError
+			if _, err := v.ValidateStream(json.NewDecoder(strings.NewReader(tc.input)), nil, func(e ValidationError) {
+				got = append(got, e)
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if want := sorted(v.Validate(data).Errors); sorted(got) != want {
+				t.Errorf("%s: expected %q, got %q", tc.name, want, sorted(got))
+			}
+		}
+
+		streaming := v.config.Load().plan.streaming
+		if fmt.Sprint(streaming.needs["rows"]) != "[mode]" {
+			t.Errorf("Expected the rows to need mode, got %v", streaming.needs["rows"])
+		}
+		if !streaming.ready("rows", map[string]interface{}{"mode": nil}) || streaming.ready("rows", map[string]interface{}{}) {
+			t.Error("Expected the rows to be streamed only after mode")
+		}
+	})
+
+	t.Run("ignored values", func(t *testing.T) {
+		errs, valid, err := stream(`{"title": "t", "rows": {"0": [{"sku": "bad"}]}, "rows": null, "rows": [1, [{"sku": "bad"}], null]}`)
+		if err != nil || !valid || len(errs) != 0 {
+			t.Errorf("Expected a valid stream, got %v, %v, %v", errs, valid, err)
+		}
+	})
+
+	t.Run("decoding errors", func(t *testing.T) {
+		for _, input := range []string{`[]`, `"text"`, `{"rows": [{"sku": "bad"}, {"sku":`, `{"rows": [}`, `{"title": "t"`} {
+			if _, valid, err := stream(input); err == nil || valid {
+				t.Errorf("Expected an error for %s", input)
+			}
+		}
+	})
+
+	t.Run("stream of objects", func(t *testing.T) {
+		dec := json.NewDecoder(strings.NewReader(`{"title": "a"} {"title": ""} {"title": "c"}`))
+		var results []bool
+		for dec.More() {
+			valid, err := v.ValidateStream(dec, nil, func(ValidationError) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			results = append(results, valid)
+		}
+		if fmt.Sprint(results) != "[true false true]" {
+			t.Errorf("Expected [true false true], got %v", results)
+		}
+	})
+}
+
+// TestToString tests conversion of values of every Go numeric kind, named types,
+// pointers, times and json.Number
+func TestToString(t *testing.T) {
+	type status string
+	type level uint8
+	n := 42
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{int8(-8), "-8"},
+		{int16(16), "16"},
+		{int32(32), "32"},
+		{uint(7), "7"},
+		{uint8(255), "255"},
+		{uint32(32), "32"},
+		{uint64(18446744073709551615), "18446744073709551615"},
+		{float32(1.1), "1.1"},
+		{float64(2.5), "2.5"},
+		{status("active"), "active"},
+		{level(3), "3"},
+		{&n, "42"},
+		{(*int)(nil), ""},
+		{json.Number("12.50"), "12.50"},
+		{time.Date(2024, 3, 9, 10, 30, 0, 0, time.UTC), "2024-03-09T10:30:00Z"},
+		{[]int{1}, ""},
+	}
+	for _, tt := range tests {
+		if got := toString(tt.value); got != tt.expected {
+			t.Errorf("toString(%#v) = %q, expected %q", tt.value, got, tt.expected)
+		}
+	}
+
+	if f, ok := toNumber(level(3)); !ok || f != 3 {
+		t.Errorf("toNumber(level(3)) = %v, %v", f, ok)
+	}
+	if f, ok := toFloat64(json.Number("1e3")); !ok || f != 1000 {
+		t.Errorf("toFloat64(json.Number) = %v, %v", f, ok)
+	}
+	if !isEmpty((*string)(nil)) || !isEmpty(status("")) || !isEmpty(time.Time{}) || isEmpty(&n) {
+		t.Error("Expected nil pointers, empty named strings and zero times to be empty")
+	}
+}
+
+// TestValidateStruct tests validation of Go structs through their tags
+func TestValidateStruct(t *testing.T) {
+	type status string
+	type Base struct {
+		ID int64 `json:"id"`
+	}
+	type Option struct {
+		Code  string `json:"code"`
+		Price uint16 `json:"price"`
+	}
+	type Order struct {
+		Base
+		Name     string      `form:"name" json:"title"`
+		Qty      uint8       `json:"qty"`
+		Weight   float32     `json:"weight"`
+		Status   status      `json:"status"`
+		Delivery time.Time   `json:"delivery"`
+		Amount   json.Number `json:"amount"`
+		Coupon   *string     `json:"coupon"`
+		Note     string      `json:"note,omitempty"`
+		Options  []Option    `json:"options"`
+		Tags     []string    `json:"tags"`
+		Secret   string      `json:"-"`
+		internal string
+	}
+	spec := Spec{Fields: []Field{
+		{Name: "id", Type: "number", Rules: map[string]interface{}{"min": 1}},
+		{Name: "name", Type: "text", Rules: map[string]interface{}{"required": true}},
+		{Name: "qty", Type: "number", Rules: map[string]interface{}{"min": 1, "max": 10}},
+		{Name: "weight", Type: "number", Rules: map[string]interface{}{"max": 1.5}},
+		{Name: "status", Type: "text", Rules: map[string]interface{}{"in": []string{"active", "paused"}}},
+		{Name: "delivery", Type: "date", Rules: map[string]interface{}{"dateISO": true}},
+		{Name: "amount", Type: "number", Rules: map[string]interface{}{"min": 10}},
+		{Name: "coupon", Type: "text", Rules: map[string]interface{}{"required": ".status == 'paused'"}},
+		{Name: "note", Type: "text", Rules: map[string]interface{}{"required": ".qty > 5"}},
+		{Name: "options", Type: "group", Multiple: true, Fields: []Field{
+			{Name: "code", Type: "text", Rules: map[string]interface{}{"required": true}},
+			{Name: "price", Type: "number", Rules: map[string]interface{}{"max": "...qty > 5 ? 500 : 1000"}},
+		}},
+		{Name: "tags", Type: "checkbox", Rules: map[string]interface{}{"required": true}},
+	}}
+	v := NewValidator(spec)
+
+	coupon := "SPRING"
+	valid := Order{
+		Base: Base{ID: 7}, Name: "Order", Qty: 2, Weight: 1.25, Status: "active",
+		Delivery: time.Date(2024, 3, 9, 15, 0, 0, 0, time.UTC), Amount: "12.50", Coupon: &coupon,
+		Options: []Option{{Code: "gift", Price: 150}}, Tags: []string{"new"}, Secret: "s", internal: "i",
+	}
+	for _, value := range []interface{}{valid, &valid} {
+		result, err := v.ValidateStruct(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.IsValid {
+			t.Errorf("Expected %T to be valid, got %v", value, result.Errors)
+		}
+	}
+
+	invalid := Order{
+		Qty: 6, Weight: 2, Status: "paused", Amount: "9",
+		Options: []Option{{Code: "gift", Price: 601}, {Price: 1}},
+	}
+	result, err := v.ValidateStruct(invalid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range result.Errors {
+		got = append(got, e.Field+":"+e.Rule)
+	}
+	expected := "id:min name:required weight:max amount:min coupon:required note:required " +
+		"options.0.price:max options.1.code:required tags:required"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("Expected errors %s, got %s", expected, strings.Join(got, " "))
+	}
+
+	converted, err := spec.structData(reflect.ValueOf(valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := converted.(map[string]interface{})
+	if _, ok := data["Secret"]; ok || data["internal"] != nil || data["title"] != nil {
+		t.Errorf("Expected skipped fields and json names of form-tagged fields to be absent, got %v", data)
+	}
+	if data["delivery"] != "2024-03-09" || data["qty"] != uint64(2) || data["weight"] != float32(1.25) {
+		t.Errorf("Unexpected converted values %v", data)
+	}
+	if _, ok := data["note"]; ok {
+		t.Error("Expected the omitempty note to be absent")
+	}
+
+	if _, err := v.ValidateStruct([]Order{valid}); err == nil {
+		t.Error("Expected an error for a slice")
+	}
+	if result, err := v.ValidateStruct(map[string]interface{}{"name": status("n")}); err != nil || len(result.Errors) == 0 {
+		t.Errorf("Expected a map to be validated, got %v, %v", result, err)
+	}
+
+	// Values that refer to themselves are errors; values shared without a cycle are not
+	type Node struct {
+		Name  string      `json:"name"`
+		Next  *Node       `json:"next"`
+		Extra interface{} `json:"extra"`
+	}
+	type Embedded struct {
+		*Embedded
+		Name string `json:"name"`
+	}
+	loop := &Node{Name: "a"}
+	loop.Next = &Node{Name: "b", Next: loop}
+	selfMap := map[string]interface{}{}
+	selfMap["self"] = selfMap
+	selfEmbedded := &Embedded{Name: "e"}
+	selfEmbedded.Embedded = selfEmbedded
+	for _, value := range []interface{}{loop, &Node{Extra: selfMap}, selfEmbedded} {
+		if _, err := v.ValidateStruct(value); err == nil || !strings.Contains(err.Error(), "cycle") {
+			t.Errorf("Expected a cycle error for %T, got %v", value, err)
+		}
+	}
+	shared := &Node{Name: "shared"}
+	if _, err := v.ValidateStruct(&Node{Next: shared, Extra: []interface{}{shared, shared}}); err != nil {
+		t.Errorf("Expected a shared pointer to be converted, got %v", err)
+	}
+}
+
+// TestBind tests decoding form data into structs with coercion by field type
+func TestBind(t *testing.T) {
+	type Option struct {
+		Code  string `json:"code"`
+		Price uint16 `json:"price"`
+	}
+	type Order struct {
+		ID       int64             `json:"id"`
+		Qty      int8              `json:"qty"`
+		Weight   float32           `json:"weight"`
+		Amount   json.Number       `json:"amount"`
+		Gift     bool              `json:"gift"`
+		Express  bool              `json:"express"`
+		Paid     *bool             `json:"paid"`
+		Delivery time.Time         `json:"delivery"`
+		Created  time.Time         `json:"created"`
+		Colors   []string          `json:"colors"`
+		Options  []Option          `json:"options"`
+		Coupon   *string           `json:"coupon"`
+		Note     string            `form:"memo"`
+		Extra    map[string]int    `json:"extra"`
+		Raw      interface{}       `json:"raw"`
+		Lookup   map[string]string `json:"-"`
+	}
+	spec := Spec{Fields: []Field{
+		{Name: "gift", Type: "checkbox"},
+		{Name: "delivery", Type: "date"},
+		{Name: "colors", Type: "multichoice"},
+		{Name: "options", Type: "group", Multiple: true, Fields: []Field{
+			{Name: "code", Type: "text"},
+			{Name: "price", Type: "number"},
+		}},
+	}}
+	v := NewValidator(spec)
+
+	var order Order
+	result, err := v.Bind(map[string]interface{}{
+		"id":       "9007199254740993",
+		"qty":      float64(12),
+		"weight":   "1.25",
+		"amount":   12.5,
+		"gift":     "on",
+		"express":  "true",
+		"paid":     "",
+		"delivery": "2024-3-9",
+		"created":  "2024-03-09T10:30:00+09:00",
+		"colors":   "red",
+		"options":  []interface{}{map[string]interface{}{"code": "a", "price": "150"}, map[string]interface{}{"code": 7}},
+		"coupon":   nil,
+		"memo":     "leave at door",
+		"extra":    map[string]interface{}{"wrap": "2"},
+		"raw":      []interface{}{"x"},
+		"Lookup":   map[string]interface{}{"a": "b"},
+		"unknown":  true,
+	}, &order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsValid {
+		t.Fatalf("Expected no bind errors, got %v", result.Errors)
+	}
+	expected := Order{
+		ID: 9007199254740993, Qty: 12, Weight: 1.25, Amount: "12.5", Gift: true, Express: true,
+		Delivery: time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC),
+		Created:  time.Date(2024, 3, 9, 10, 30, 0, 0, time.FixedZone("", 9*3600)),
+		Colors:   []string{"red"},
+		Options:  []Option{{Code: "a", Price: 150}, {Code: "7"}},
+		Note:     "leave at door", Extra: map[string]int{"wrap": 2}, Raw: []interface{}{"x"},
+	}
+	if !expected.Created.Equal(order.Created) {
+		t.Errorf("Expected created %v, got %v", expected.Created, order.Created)
+	}
+	order.Created = expected.Created
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, order)
+	}
+
+	var failed Order
+	result, _ = v.Bind(map[string]interface{}{
+		"id":       "12abc",
+		"qty":      float64(300),
+		"weight":   true,
+		"gift":     "yes",
+		"express":  "on",
+		"delivery": "tomorrow",
+		"created":  "2024-03-09",
+		"options":  []interface{}{map[string]interface{}{"price": "-1"}, map[string]interface{}{"price": 1.5}, "x"},
+		"colors":   map[string]interface{}{"a": "b"},
+	}, &failed)
+	var got []string
+	for _, e := range result.Errors {
+		if e.Rule != "bind" {
+			t.Errorf("Expected the bind rule, got %s", e.Rule)
+		}
+		got = append(got, e.Field)
+	}
+	sort.Strings(got)
+	want := "colors created delivery express id options.0.price options.1.price options.2 qty weight"
+	if strings.Join(got, " ") != want || result.IsValid {
+		t.Errorf("Expected errors at %s, got %s", want, strings.Join(got, " "))
+	}
+	if !failed.Gift || failed.Options[0].Price != 0 {
+		t.Errorf("Expected the values that decode to be bound, got %+v", failed)
+	}
+
+	for _, target := range []interface{}{order, (*Order)(nil), new(int)} {
+		if _, err := v.Bind(map[string]interface{}{}, target); err == nil {
+			t.Errorf("Expected an error for %T", target)
+		}
+	}
+	m := map[string]interface{}{}
+	if _, err := v.Bind(map[string]interface{}{"a": 1.0}, &m); err != nil || m["a"] != 1.0 {
+		t.Errorf("Expected a map to be bound, got %v, %v", m, err)
+	}
+}
+
+// TestUniqueKeyItems tests repeatable fields keyed by unique keys in validation,
+// conditions, streaming and binding
+func TestUniqueKeyItems(t *testing.T) {
+	const a, b = "__aaaaaaaaaaaa1__", "__bbbbbbbbbbbb2__"
+	spec := Spec{Fields: []Field{
+		{Name: "mode", Type: "text"},
+		{Name: "items", Type: "group", Multiple: true, Fields: []Field{
+			{Name: "sku", Type: "text", Rules: map[string]interface{}{"required": true}},
+			{Name: "qty", Type: "number", Rules: map[string]interface{}{"max": "...mode == 'retail' ? 10 : 1000"}},
+			{Name: "note", Type: "text", Rules: map[string]interface{}{"required": ".qty > 5"}},
+		}},
+		{Name: "summary", Type: "text", Rules: map[string]interface{}{"required": "any(items.*.qty > 50)"}},
+		{Name: "tags", Type: "text", Multiple: true, Rules: map[string]interface{}{"maxcount": 1}},
+	}}
+	v := NewValidator(spec)
+	data := map[string]interface{}{
+		"mode": "retail",
+		"items": map[string]interface{}{
+			b: map[string]interface{}{"sku": "", "qty": 3},
+			a: map[string]interface{}{"sku": "x", "qty": 60},
+		},
+		"tags": map[string]interface{}{b: "y", a: "x"},
+	}
+
+	fields := func(errs []ValidationError) string {
+		var got []string
+		for _, e := range errs {
+			got = append(got, e.Field+":"+e.Rule)
+		}
+		return strings.Join(got, " ")
+	}
+	want := "items." + a + ".qty:max items." + a + ".note:required items." + b + ".sku:required summary:required tags:maxcount"
+	if got := fields(v.Validate(data).Errors); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	// Wildcards expand over unique keys in key order
+	paths := NewPathResolver(data).ExpandWildcardPath([]string{"items", "*", "qty"})
+	if fmt.Sprint(paths) != "[[items "+a+" qty] [items "+b+" qty]]" {
+		t.Errorf("Unexpected expansion %v", paths)
+	}
+	if MustCompile("items.*.qty").EvaluateValue(data, []string{"items", b, "note"}) != 3 {
+		t.Error("Expected the wildcard to bind to the current unique key")
+	}
+
+	// A map with other keys is not a collection of items
+	data["items"] = map[string]interface{}{"first": map[string]interface{}{"sku": ""}}
+	if got := fields(v.Validate(data).Errors); got != "tags:maxcount" {
+		t.Errorf("Expected only tags:maxcount, got %s", got)
+	}
+
+	t.Run("stream", func(t *testing.T) {
+		// Without summary, which reads every item, the items are streamed
+		v := NewValidator(Spec{Fields: []Field{spec.Fields[0], spec.Fields[1], spec.Fields[3]}})
+		stream := func(input string) ([]ValidationError, bool, error) {
+			var errs []ValidationError
+			valid, err := v.ValidateStream(json.NewDecoder(strings.NewReader(input)), nil, func(e ValidationError) {
+				errs = append(errs, e)
+			})
+			return errs, valid, err
+		}
+		items := `"` + b + `": {"sku": "", "qty": 3}, "` + a + `": {"sku": "x", "qty": 60}`
+		errs, valid, err := stream(`{"mode": "retail", "items": {` + items + `}}`)
+		want := "items." + b + ".sku:required items." + a + ".qty:max items." + a + ".note:required"
+		if err != nil || valid || fields(errs) != want {
+			t.Errorf("Expected %s, got %s (%v)", want, fields(errs), err)
+		}
+
+		// As in Validate, a key that is not a unique key drops the errors of the items
+		for _, input := range []string{
+			`{"mode": "retail", "items": {` + items + `, "x": {"sku": ""}}}`,
+			`{"mode": "retail", "items": {"x": [{"sku": ""}], ` + items + `}}`,
+		} {
+			if errs, valid, err := stream(input); err != nil || !valid {
+				t.Errorf("Expected a valid stream for %s, got %s (%v)", input, fields(errs), err)
+			}
+		}
+	})
+
+	t.Run("bind", func(t *testing.T) {
+		var form struct {
+			Items []struct {
+				SKU string  `json:"sku"`
+				Qty float64 `json:"qty"`
+			} `json:"items"`
+			Tags []int `json:"tags"`
+		}
+		result, err := v.Bind(map[string]interface{}{
+			"items": map[string]interface{}{b: map[string]interface{}{"sku": "b"}, a: map[string]interface{}{"sku": "a"}},
+			"tags":  map[string]interface{}{a: "x"},
+		}, &form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(form.Items) != 2 || form.Items[0].SKU != "a" || form.Items[1].SKU != "b" {
+			t.Errorf("Expected the items in key order, got %+v", form.Items)
+		}
+		if fields(result.Errors) != "tags."+a+":bind" {
+			t.Errorf("Expected a bind error at the unique key, got %s", fields(result.Errors))
+		}
+	})
+}
+
 // Helper function
 func floatPtr(f float64) *float64 {
 	return &f